@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidWorkerCount se devuelve al redimensionar el pool fuera de sus límites.
+var ErrInvalidWorkerCount = errors.New("worker count out of range")
+
+// TargetWorkers devuelve el número de workers que tendrá el pool cuando se
+// retiren los workers ocupados pendientes de retirada.
+func (d *Dispatcher) TargetWorkers() int {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	return len(d.workers) - d.retiring
+}
+
+// Resize fija el número de workers del pool. Los workers nuevos empiezan a
+// recibir trabajos de inmediato; al reducir el pool se retiran primero los
+// workers libres y los ocupados se retiran al terminar su trabajo actual.
+//
+// Si el escalado automático está activo, seguirá ajustando el pool a partir
+// del nuevo tamaño.
+//
+// Retorna ErrInvalidWorkerCount si n está fuera de [MinWorkers, MaxWorkers].
+func (d *Dispatcher) Resize(n int) error {
+	if n < d.MinWorkers() || n > d.MaxWorkers {
+		return fmt.Errorf("%w: must be between %d and %d", ErrInvalidWorkerCount, d.MinWorkers(), d.MaxWorkers)
+	}
+	if from := d.resize(n); n != from {
+		d.Logger.Info("workers resized by admin", "from", from, "to", n)
+	}
+	return nil
+}
+
+// resize ajusta el pool a n workers sin validar los límites y devuelve el
+// número de workers previsto antes del cambio.
+func (d *Dispatcher) resize(n int) int {
+	d.workersMu.Lock()
+	from := len(d.workers) - d.retiring
+	if n >= from {
+		kept := min(d.retiring, n-from) // Cancela retiradas pendientes antes de crear workers.
+		d.retiring -= kept
+		d.startWorkers(n - from - kept)
+	} else {
+		d.retiring += from - n
+	}
+	d.workersMu.Unlock()
+
+	d.retireIdleWorkers()
+	return from
+}
+
+// retireIdleWorkers detiene workers libres mientras queden retiradas pendientes.
+func (d *Dispatcher) retireIdleWorkers() {
+	for d.TargetWorkers() < d.WorkerCount() {
+		var workerJobQueue chan Job
+		select {
+		case workerJobQueue = <-d.WorkerPool:
+		default:
+			return // Los workers restantes se retirarán al terminar su trabajo.
+		}
+
+		d.workersMu.Lock()
+		if d.retiring == 0 { // Otro worker reclamó la última retirada.
+			d.workersMu.Unlock()
+			d.WorkerPool <- workerJobQueue // Devuelve el worker al pool.
+			return
+		}
+		d.retiring--
+		worker := d.detachWorker(func(w *Worker) bool { return w.JobQueue == workerJobQueue })
+		d.workersMu.Unlock()
+		worker.Stop()
+	}
+}
+
+// claimRetirement se asigna como Worker.Retire: si quedan retiradas
+// pendientes, quita al worker del roster y le indica que se detenga.
+func (d *Dispatcher) claimRetirement(worker *Worker) bool {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	if d.retiring == 0 {
+		return false
+	}
+	d.retiring--
+	d.detachWorker(func(w *Worker) bool { return w == worker })
+	return true
+}
+
+// workerRoster es la respuesta de GET /admin/workers.
+type workerRoster struct {
+	Count       int            `json:"count"`  // Workers en funcionamiento
+	Target      int            `json:"target"` // Workers tras las retiradas pendientes
+	Min         int            `json:"min"`
+	Max         int            `json:"max"`
+	Autoscaling bool           `json:"autoscaling"`
+	Workers     []WorkerStatus `json:"workers"`
+}
+
+// AdminHandler maneja la administración del pool de workers:
+//   - GET /admin/workers: devuelve el roster con el estado de cada worker.
+//   - PUT /admin/workers: fija el número de workers a partir de un cuerpo
+//     JSON {"count": N} y responde con el roster. Responde 202 si quedan
+//     workers ocupados pendientes de retirarse y 200 si no.
+func AdminHandler(w http.ResponseWriter, r *http.Request, dispatcher *Dispatcher) {
+	if strings.TrimSuffix(r.URL.Path, "/") != "/admin/workers" {
+		writeError(w, http.StatusNotFound, "not_found", "Not found", nil)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, newWorkerRoster(dispatcher))
+
+	case http.MethodPut:
+		var body struct {
+			Count *int `json:"count"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Count == nil {
+			writeError(w, http.StatusBadRequest, "invalid_json", `Body must be a JSON object like {"count": N}`, nil)
+			return
+		}
+		if err := dispatcher.Resize(*body.Count); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_request", "Invalid worker count", FieldErrors{"count": err.Error()})
+			return
+		}
+		roster := newWorkerRoster(dispatcher)
+		status := http.StatusOK
+		if roster.Count != roster.Target {
+			status = http.StatusAccepted
+		}
+		writeJSON(w, status, roster)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPut)
+	}
+}
+
+// newWorkerRoster toma una instantánea del pool del dispatcher.
+func newWorkerRoster(d *Dispatcher) workerRoster {
+	roster := workerRoster{
+		Min:         d.MinWorkers(),
+		Max:         d.MaxWorkers,
+		Autoscaling: d.Scaling != nil,
+	}
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	roster.Workers = make([]WorkerStatus, len(d.workers))
+	for i, worker := range d.workers {
+		roster.Workers[i] = worker.Status()
+	}
+	roster.Count = len(d.workers)
+	roster.Target = len(d.workers) - d.retiring
+	return roster
+}
@@ -0,0 +1,123 @@
+// Este archivo contiene pruebas unitarias para la API de administración del
+// pool de workers.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAdminHandlerResize verifica que PUT /admin/workers valide el cuerpo y
+// los límites del pool y que el roster refleje el nuevo tamaño.
+func TestAdminHandlerResize(t *testing.T) {
+	d := NewDispatcher(NewJobQueue(10, 0, time.Second), 4, NewJobStore(), nil)
+	d.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		d.Stop(ctx)
+	})
+
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "shrink", body: `{"count": 2}`, wantStatus: http.StatusOK, wantCount: 2},
+		{name: "grow", body: `{"count": 3}`, wantStatus: http.StatusOK, wantCount: 3},
+		{name: "above max", body: `{"count": 5}`, wantStatus: http.StatusUnprocessableEntity, wantCount: 3},
+		{name: "below min", body: `{"count": 0}`, wantStatus: http.StatusUnprocessableEntity, wantCount: 3},
+		{name: "missing count", body: `{}`, wantStatus: http.StatusBadRequest, wantCount: 3},
+		{name: "invalid json", body: `count=2`, wantStatus: http.StatusBadRequest, wantCount: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			waitForIdleWorkers(t, d)
+			rec := httptest.NewRecorder()
+			AdminHandler(rec, httptest.NewRequest(http.MethodPut, "/admin/workers", strings.NewReader(tc.body)), d)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", rec.Code, tc.wantStatus, rec.Body)
+			}
+
+			rec = httptest.NewRecorder()
+			AdminHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/workers", nil), d)
+			var roster workerRoster
+			if err := json.NewDecoder(rec.Body).Decode(&roster); err != nil {
+				t.Fatalf("decoding roster: %v", err)
+			}
+			if roster.Count != tc.wantCount || len(roster.Workers) != tc.wantCount {
+				t.Errorf("roster count = %d with %d worker(s); want %d", roster.Count, len(roster.Workers), tc.wantCount)
+			}
+		})
+	}
+}
+
+// TestResizeRetiresBusyWorkers verifica que al reducir el pool los workers
+// ocupados terminen su trabajo antes de retirarse.
+func TestResizeRetiresBusyWorkers(t *testing.T) {
+	store := NewJobStore()
+	d := NewDispatcher(NewJobQueue(10, 0, time.Second), 2, store, nil)
+	d.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		d.Stop(ctx)
+	})
+
+	jobs := []Job{
+		NewJob("slow-1", 1, 100*time.Millisecond, time.Time{}, PriorityNormal),
+		NewJob("slow-2", 1, 100*time.Millisecond, time.Time{}, PriorityNormal),
+	}
+	for _, job := range jobs {
+		if err := d.Enqueue(job, 0); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	for d.IdleWorkers() > 0 {
+		time.Sleep(time.Millisecond) // Espera a que ambos workers estén ocupados.
+	}
+
+	if err := d.Resize(1); err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+	if got, target := d.WorkerCount(), d.TargetWorkers(); got != 2 || target != 1 {
+		t.Fatalf("WorkerCount(), TargetWorkers() = %d, %d; want 2, 1", got, target)
+	}
+
+	for _, job := range jobs {
+		done, _ := store.Done(job.ID)
+		<-done
+	}
+	deadline := time.Now().Add(time.Second)
+	for d.WorkerCount() > 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := d.WorkerCount(); got != 1 {
+		t.Errorf("WorkerCount() after jobs finished = %d; want 1", got)
+	}
+	for _, job := range jobs {
+		if rec, _ := store.Get(job.ID); rec.Status != StatusDone {
+			t.Errorf("job %s status = %s; want %s", job.Name, rec.Status, StatusDone)
+		}
+	}
+}
+
+// waitForIdleWorkers espera a que todos los workers del pool, salvo el que
+// reserva el Dispatcher, estén registrados en el WorkerPool.
+func waitForIdleWorkers(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(d.WorkerPool) < d.WorkerCount()-1 {
+		if time.Now().After(deadline) {
+			t.Fatal("workers did not become idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
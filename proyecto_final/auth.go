@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Permisos que puede tener una API key.
+const (
+	ScopeJobsSubmit = "jobs:submit" // Crear y cancelar trabajos propios
+	ScopeJobsRead   = "jobs:read"   // Consultar trabajos, lotes y eventos propios
+	ScopeAdmin      = "admin"       // Todo lo anterior sobre cualquier trabajo, y la administración
+)
+
+// APIKey es una entrada del archivo de API keys. La clave no se guarda: solo
+// su hash SHA-256 en hexadecimal (ver HashAPIKey). Con mTLS, la identidad
+// también se puede acreditar con un certificado de cliente cuyo sujeto sea
+// Subject; una entrada necesita al menos uno de los dos.
+type APIKey struct {
+	Name    string   `json:"name"`                   // Identidad del dueño de la clave
+	Hash    string   `json:"key_sha256,omitempty"`   // SHA-256 de la clave en hexadecimal
+	Subject string   `json:"cert_subject,omitempty"` // Sujeto del certificado de cliente, Ej: "CN=alice,O=Acme"
+	Scopes  []string `json:"scopes"`
+}
+
+// Identity es el cliente autenticado que hace una solicitud.
+type Identity struct {
+	Name   string
+	Scopes []string
+}
+
+// Can indica si la identidad tiene el permiso scope. El permiso admin
+// incluye todos los demás.
+func (id Identity) Can(scope string) bool {
+	return slices.Contains(id.Scopes, scope) || slices.Contains(id.Scopes, ScopeAdmin)
+}
+
+// Authenticator valida las API keys y los certificados de cliente de las
+// solicitudes. Un *Authenticator nil no exige autenticación.
+type Authenticator struct {
+	keys     map[string]Identity // Identidad de cada clave por su hash
+	subjects map[string]Identity // Identidad de cada sujeto de certificado
+}
+
+// HashAPIKey devuelve el hash con el que se guarda una API key. Las claves
+// son aleatorias y largas, por lo que basta un SHA-256 sin sal.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAuthenticator crea un autenticador con las claves indicadas.
+//
+// Retorna un error si alguna entrada no tiene nombre, no tiene clave ni
+// sujeto, tiene un hash mal formado, una clave o sujeto repetido, o un
+// permiso desconocido.
+func NewAuthenticator(keys []APIKey) (*Authenticator, error) {
+	a := &Authenticator{keys: make(map[string]Identity), subjects: make(map[string]Identity)}
+	for i, key := range keys {
+		hash := strings.ToLower(key.Hash)
+		switch {
+		case key.Name == "":
+			return nil, fmt.Errorf("api key %d: name is required", i)
+		case hash == "" && key.Subject == "":
+			return nil, fmt.Errorf("api key %q: key_sha256 or cert_subject is required", key.Name)
+		case hash != "" && (len(hash) != sha256.Size*2 || strings.Trim(hash, "0123456789abcdef") != ""):
+			return nil, fmt.Errorf("api key %q: key_sha256 must be a hex SHA-256 hash", key.Name)
+		case len(key.Scopes) == 0:
+			return nil, fmt.Errorf("api key %q: at least one scope is required", key.Name)
+		}
+		for _, scope := range key.Scopes {
+			if scope != ScopeJobsSubmit && scope != ScopeJobsRead && scope != ScopeAdmin {
+				return nil, fmt.Errorf("api key %q: unknown scope %q", key.Name, scope)
+			}
+		}
+		id := Identity{Name: key.Name, Scopes: key.Scopes}
+		if hash != "" {
+			if _, dup := a.keys[hash]; dup {
+				return nil, fmt.Errorf("api key %q: duplicate key_sha256", key.Name)
+			}
+			a.keys[hash] = id
+		}
+		if key.Subject != "" {
+			if _, dup := a.subjects[key.Subject]; dup {
+				return nil, fmt.Errorf("api key %q: duplicate cert_subject", key.Name)
+			}
+			a.subjects[key.Subject] = id
+		}
+	}
+	return a, nil
+}
+
+// LoadAPIKeys crea un autenticador con las claves del archivo JSON path, que
+// contiene un array de APIKey.
+func LoadAPIKeys(path string) (*Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading api keys: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewAuthenticator(keys)
+}
+
+// Authenticate devuelve la identidad de la solicitud: la del sujeto de su
+// certificado de cliente si la conexión lo verificó y está registrado, o si
+// no la de su API key, enviada como "Authorization: Bearer <clave>" o en la
+// cabecera X-API-Key.
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, bool) {
+	if subject, ok := clientSubject(r); ok {
+		if id, ok := a.subjects[subject]; ok {
+			return id, true
+		}
+	}
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); key == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		key = strings.TrimSpace(auth[7:])
+	}
+	if key == "" {
+		return Identity{}, false
+	}
+	id, ok := a.keys[HashAPIKey(key)]
+	return id, ok
+}
+
+// clientSubject devuelve el sujeto del certificado de cliente de la
+// solicitud, en el formato de RFC 2253, si la conexión TLS lo verificó.
+func clientSubject(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.String(), true
+}
+
+// Require devuelve next precedido por la autenticación: responde 401 si la
+// solicitud no trae una API key o un certificado de cliente válido y 403 si
+// su identidad no tiene el permiso scope. La identidad queda en el contexto
+// de la solicitud (ver CallerFrom). Con un Authenticator nil devuelve next
+// sin cambios.
+func (a *Authenticator) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := a.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fibonacci"`)
+			writeError(w, http.StatusUnauthorized, "unauthorized", "A valid API key or client certificate is required", nil)
+			return
+		}
+		if !id.Can(scope) {
+			writeError(w, http.StatusForbidden, "forbidden", "API key lacks the "+scope+" scope", nil)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+}
+
+// identityKey es la clave de la identidad en el contexto de una solicitud.
+type identityKey struct{}
+
+// CallerFrom devuelve la identidad autenticada guardada en ctx por Require.
+// El segundo valor es false si la solicitud no pasó por la autenticación.
+func CallerFrom(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// requestOwner devuelve el dueño que se asigna a los trabajos creados por la
+// solicitud, o vacío si la autenticación está desactivada.
+func requestOwner(r *http.Request) string {
+	id, _ := CallerFrom(r.Context())
+	return id.Name
+}
+
+// visibleTo indica si el cliente de la solicitud puede ver y cancelar un
+// trabajo o lote de owner: siempre que la autenticación esté desactivada o
+// tenga el permiso admin, y si no, solo si es el dueño.
+func visibleTo(r *http.Request, owner string) bool {
+	id, ok := CallerFrom(r.Context())
+	return !ok || slices.Contains(id.Scopes, ScopeAdmin) || id.Name == owner
+}
@@ -0,0 +1,155 @@
+// Este archivo contiene pruebas unitarias para la autenticación con API
+// keys: la validación del archivo de claves, los permisos y la visibilidad
+// de los trabajos de cada cliente.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeys son las claves de las pruebas: alice y bob envían y consultan
+// trabajos, reader solo consulta y ops administra.
+var testKeys = []APIKey{
+	{Name: "alice", Hash: HashAPIKey("alice-key"), Scopes: []string{ScopeJobsSubmit, ScopeJobsRead}},
+	{Name: "bob", Hash: HashAPIKey("bob-key"), Scopes: []string{ScopeJobsSubmit, ScopeJobsRead}},
+	{Name: "reader", Hash: HashAPIKey("reader-key"), Scopes: []string{ScopeJobsRead}},
+	{Name: "ops", Hash: HashAPIKey("ops-key"), Scopes: []string{ScopeAdmin}},
+}
+
+// TestAuthenticatorRequire verifica las respuestas a las solicitudes sin
+// clave, con una clave desconocida o sin el permiso necesario.
+func TestAuthenticatorRequire(t *testing.T) {
+	auth, err := NewAuthenticator(testKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.Require(ScopeJobsSubmit, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(requestOwner(r)))
+	})
+
+	testCases := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "missing key", wantStatus: http.StatusUnauthorized, wantBody: `"code":"unauthorized"`},
+		{name: "unknown key", header: "X-API-Key", value: "guess", wantStatus: http.StatusUnauthorized, wantBody: `"code":"unauthorized"`},
+		{name: "missing scope", header: "X-API-Key", value: "reader-key", wantStatus: http.StatusForbidden, wantBody: `"code":"forbidden"`},
+		{name: "api key header", header: "X-API-Key", value: "alice-key", wantStatus: http.StatusOK, wantBody: "alice"},
+		{name: "bearer token", header: "Authorization", value: "Bearer bob-key", wantStatus: http.StatusOK, wantBody: "bob"},
+		{name: "admin has every scope", header: "Authorization", value: "bearer ops-key", wantStatus: http.StatusOK, wantBody: "ops"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fibonacci", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tc.wantStatus || !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Errorf("response = %d %s; want %d containing %s", rec.Code, rec.Body, tc.wantStatus, tc.wantBody)
+			}
+			if tc.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header missing")
+			}
+		})
+	}
+}
+
+// TestNewAuthenticatorValidation verifica que se rechacen las entradas mal
+// formadas del archivo de claves.
+func TestNewAuthenticatorValidation(t *testing.T) {
+	hash := HashAPIKey("key")
+	testCases := []struct {
+		name string
+		keys []APIKey
+	}{
+		{name: "missing name", keys: []APIKey{{Hash: hash, Scopes: []string{ScopeJobsRead}}}},
+		{name: "plain key", keys: []APIKey{{Name: "a", Hash: "key", Scopes: []string{ScopeJobsRead}}}},
+		{name: "no key nor subject", keys: []APIKey{{Name: "a", Scopes: []string{ScopeJobsRead}}}},
+		{name: "no scopes", keys: []APIKey{{Name: "a", Hash: hash}}},
+		{name: "unknown scope", keys: []APIKey{{Name: "a", Hash: hash, Scopes: []string{"jobs:write"}}}},
+		{name: "duplicate", keys: []APIKey{{Name: "a", Hash: hash, Scopes: []string{ScopeJobsRead}}, {Name: "b", Hash: strings.ToUpper(hash), Scopes: []string{ScopeJobsRead}}}},
+		{name: "duplicate subject", keys: []APIKey{{Name: "a", Subject: "CN=a", Scopes: []string{ScopeJobsRead}}, {Name: "b", Subject: "CN=a", Scopes: []string{ScopeJobsRead}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewAuthenticator(tc.keys); err == nil {
+				t.Error("NewAuthenticator() error = nil; want error")
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	data, _ := json.Marshal(testKeys)
+	writeFile(t, path, string(data))
+	if _, err := LoadAPIKeys(path); err != nil {
+		t.Errorf("LoadAPIKeys() error = %v", err)
+	}
+}
+
+// TestJobOwnership verifica que cada cliente solo vea y cancele sus
+// trabajos, y que el permiso admin vea los de todos.
+func TestJobOwnership(t *testing.T) {
+	auth, err := NewAuthenticator(testKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(5, 0, time.Second), 1, store, nil)
+	submit := auth.Require(ScopeJobsSubmit, func(w http.ResponseWriter, r *http.Request) {
+		RequestHandler(w, r, dispatcher, store, RequestOptions{MaxValue: 1000})
+	})
+	read := auth.Require(ScopeJobsRead, func(w http.ResponseWriter, r *http.Request) {
+		JobHandler(w, r, store, dispatcher.Logger)
+	})
+	cancel := auth.Require(ScopeJobsSubmit, func(w http.ResponseWriter, r *http.Request) {
+		JobHandler(w, r, store, dispatcher.Logger)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/fibonacci", strings.NewReader(`{"name": "mine", "value": 10, "delay": "0s"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "alice-key")
+	rec := httptest.NewRecorder()
+	submit(rec, req)
+	var job JobRecord
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil || job.Owner != "alice" {
+		t.Fatalf("created job = %+v, %v; want owner alice", job, err)
+	}
+
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		key        string
+		wantStatus int
+	}{
+		{name: "owner reads", handler: read, method: http.MethodGet, key: "alice-key", wantStatus: http.StatusOK},
+		{name: "other client reads", handler: read, method: http.MethodGet, key: "bob-key", wantStatus: http.StatusNotFound},
+		{name: "admin reads", handler: read, method: http.MethodGet, key: "ops-key", wantStatus: http.StatusOK},
+		{name: "other client cancels", handler: cancel, method: http.MethodDelete, key: "bob-key", wantStatus: http.StatusNotFound},
+		{name: "reader cannot cancel", handler: cancel, method: http.MethodDelete, key: "reader-key", wantStatus: http.StatusForbidden},
+		{name: "owner cancels", handler: cancel, method: http.MethodDelete, key: "alice-key", wantStatus: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/fibonacci/"+job.ID, nil)
+			req.Header.Set("X-API-Key", tc.key)
+			rec := httptest.NewRecorder()
+			tc.handler(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d; want %d (%s)", rec.Code, tc.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ScalingPolicy define cómo ajusta el Dispatcher el número de workers según
+// la carga. El pool crece hasta MaxWorkers cuando los trabajos se acumulan en
+// el JobQueue y se reduce hasta MinWorkers cuando sobran workers.
+type ScalingPolicy struct {
+	MinWorkers int           // Número mínimo de workers del pool
+	Interval   time.Duration // Cada cuánto se evalúa la carga
+	Backlog    int           // Trabajos en cola por worker que provocan añadir workers
+	QueueWait  time.Duration // Espera en cola que provoca añadir workers
+	Cooldown   time.Duration // Tiempo sin acumulación antes de retirar workers libres
+}
+
+// ScalingEvent describe un cambio en el número de workers del pool.
+type ScalingEvent struct {
+	From   int       // Workers antes del cambio
+	To     int       // Workers después del cambio
+	Reason string    // Motivo del cambio
+	At     time.Time // Momento del cambio
+}
+
+// MinWorkers devuelve el número mínimo de workers del pool: Scaling.MinWorkers
+// si el escalado automático está activo o 1 si no lo está.
+func (d *Dispatcher) MinWorkers() int {
+	if d.Scaling == nil {
+		return 1
+	}
+	return d.Scaling.MinWorkers
+}
+
+// autoscale evalúa la carga cada Scaling.Interval hasta que Stop lo detenga.
+// Este método bloquea y debe ejecutarse en una goroutine separada.
+func (d *Dispatcher) autoscale() {
+	defer close(d.scalerDone)
+	ticker := time.NewTicker(d.Scaling.Interval)
+	defer ticker.Stop()
+	d.lastBusy = time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.retireIdleWorkers() // Retiradas pendientes de workers que quedaron libres.
+			d.scale(now)
+		case <-d.scalerQuit:
+			return
+		}
+	}
+}
+
+// scale toma una decisión de escalado a partir del estado actual del JobQueue:
+//   - Si hay al menos Backlog trabajos en cola por worker, o el trabajo más
+//     antiguo lleva esperando QueueWait o más, añade los workers necesarios
+//     para atender la cola sin superar MaxWorkers.
+//   - Si la cola lleva vacía al menos Cooldown, retira un worker libre sin
+//     bajar de MinWorkers.
+func (d *Dispatcher) scale(now time.Time) {
+	policy := d.Scaling
+	workers := d.TargetWorkers()
+	backlog := d.JobQueue.Len()
+	wait := d.JobQueue.OldestWait()
+
+	if backlog > 0 {
+		d.lastBusy = now
+	}
+
+	var reason string
+	switch {
+	case backlog > 0 && backlog >= policy.Backlog*workers:
+		reason = fmt.Sprintf("%d job(s) queued for %d worker(s)", backlog, workers)
+	case backlog > 0 && wait >= policy.QueueWait:
+		reason = fmt.Sprintf("oldest queued job waiting %v", wait.Round(time.Millisecond))
+	}
+	if reason != "" && workers < d.MaxWorkers {
+		added := min(d.MaxWorkers-workers, max(1, backlog/policy.Backlog))
+		d.resize(workers + added)
+		d.notifyScaling(ScalingEvent{From: workers, To: workers + added, Reason: reason, At: now})
+		return
+	}
+
+	if backlog == 0 && workers > policy.MinWorkers && now.Sub(d.lastBusy) >= policy.Cooldown {
+		if d.removeWorker() {
+			d.notifyScaling(ScalingEvent{
+				From:   workers,
+				To:     workers - 1,
+				Reason: fmt.Sprintf("queue empty for %v", now.Sub(d.lastBusy).Round(time.Second)),
+				At:     now,
+			})
+		}
+	}
+}
+
+// addWorkers crea e inicia n workers nuevos.
+func (d *Dispatcher) addWorkers(n int) {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	d.startWorkers(n)
+}
+
+// startWorkers crea e inicia n workers nuevos. Debe llamarse con workersMu tomado.
+func (d *Dispatcher) startWorkers(n int) {
+	for range n {
+		worker := NewWorker(d.nextWorkerID, d.WorkerPool, d.Store, d.handleFailure, d.Logger) // Crea un nuevo trabajador.
+		worker.Retire = d.claimRetirement
+		worker.Cache = d.Cache
+		worker.Start() // Inicia el trabajador.
+		d.workers = append(d.workers, worker)
+		d.nextWorkerID++
+	}
+}
+
+// removeWorker detiene un worker libre y espera a que termine. Para que el
+// Dispatcher no le entregue más trabajos, el worker se retira primero del
+// WorkerPool; si ninguno está esperando en el pool no se retira ninguno y
+// devuelve false.
+func (d *Dispatcher) removeWorker() bool {
+	var workerJobQueue chan Job
+	select {
+	case workerJobQueue = <-d.WorkerPool:
+	default:
+		return false // Todos los workers están ocupados.
+	}
+
+	d.workersMu.Lock()
+	worker := d.detachWorker(func(w *Worker) bool { return w.JobQueue == workerJobQueue })
+	d.workersMu.Unlock()
+
+	worker.Stop()
+	<-worker.stopped
+	return true
+}
+
+// detachWorker quita del roster el primer worker que cumpla match y lo
+// devuelve. Debe llamarse con workersMu tomado.
+func (d *Dispatcher) detachWorker(match func(*Worker) bool) *Worker {
+	i := slices.IndexFunc(d.workers, match)
+	if i < 0 {
+		return nil
+	}
+	worker := d.workers[i]
+	d.workers = slices.Delete(d.workers, i, i+1)
+	return worker
+}
+
+// notifyScaling registra un cambio en el pool y lo entrega a OnScale.
+func (d *Dispatcher) notifyScaling(ev ScalingEvent) {
+	d.Logger.Info("workers scaled", "from", ev.From, "to", ev.To, "reason", ev.Reason)
+	if d.OnScale != nil {
+		d.OnScale(ev)
+	}
+}
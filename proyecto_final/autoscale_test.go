@@ -0,0 +1,102 @@
+// Este archivo contiene pruebas unitarias para el escalado automático del
+// pool de workers. Las decisiones se fuerzan llamando a scale con un instante
+// concreto, sin esperar al ticker.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newScalingDispatcher crea un dispatcher con escalado automático y sus
+// workers mínimos en marcha, pero sin despachar trabajos, de modo que los
+// trabajos encolados permanecen en el JobQueue.
+func newScalingDispatcher(t *testing.T, minWorkers, maxWorkers int) *Dispatcher {
+	t.Helper()
+	d := NewDispatcher(NewJobQueue(20, 0, time.Second), maxWorkers, NewJobStore(), nil)
+	d.Scaling = &ScalingPolicy{
+		MinWorkers: minWorkers,
+		Interval:   time.Hour,
+		Backlog:    2,
+		QueueWait:  time.Minute,
+		Cooldown:   time.Minute,
+	}
+	d.addWorkers(minWorkers)
+	close(d.scalerDone)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		close(d.done)
+		d.Stop(ctx)
+	})
+	return d
+}
+
+// TestScaleUp verifica cuántos workers se añaden según el número de
+// trabajos en cola, sin superar MaxWorkers.
+func TestScaleUp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backlog int
+		want    int
+	}{
+		{name: "empty queue", backlog: 0, want: 1},
+		{name: "below threshold", backlog: 1, want: 1},
+		{name: "at threshold", backlog: 2, want: 2},
+		{name: "large backlog", backlog: 6, want: 4},
+		{name: "capped at max", backlog: 20, want: 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newScalingDispatcher(t, 1, 5)
+			var events []ScalingEvent
+			d.OnScale = func(ev ScalingEvent) { events = append(events, ev) }
+			for range tc.backlog {
+				if err := d.JobQueue.Push(NewJob("job", 1, 0, time.Time{}, PriorityNormal), 0); err != nil {
+					t.Fatalf("Push() error = %v", err)
+				}
+			}
+
+			d.scale(time.Now())
+
+			if got := d.WorkerCount(); got != tc.want {
+				t.Errorf("WorkerCount() = %d; want %d", got, tc.want)
+			}
+			if scaled := tc.want > 1; scaled != (len(events) == 1) {
+				t.Errorf("got %d scaling event(s); want scaled = %v", len(events), scaled)
+			}
+		})
+	}
+}
+
+// TestScaleDown verifica que los workers libres se retiren de uno en uno
+// tras el periodo de enfriamiento y nunca por debajo de MinWorkers.
+func TestScaleDown(t *testing.T) {
+	d := newScalingDispatcher(t, 1, 3)
+	d.addWorkers(2)
+	start := time.Now()
+	d.lastBusy = start
+
+	d.scale(start.Add(time.Second)) // Aún dentro del periodo de enfriamiento.
+	if got := d.WorkerCount(); got != 3 {
+		t.Fatalf("WorkerCount() during cooldown = %d; want 3", got)
+	}
+
+	later := start.Add(2 * time.Minute)
+	for want := 2; want >= 1; want-- {
+		deadline := time.Now().Add(time.Second)
+		for d.WorkerCount() > want && time.Now().Before(deadline) {
+			d.scale(later) // Reintenta hasta que el worker se registre en el pool.
+		}
+		if got := d.WorkerCount(); got != want {
+			t.Fatalf("WorkerCount() = %d; want %d", got, want)
+		}
+	}
+
+	d.scale(later)
+	if got := d.WorkerCount(); got != 1 {
+		t.Errorf("WorkerCount() below minimum = %d; want 1", got)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Batch es un grupo de trabajos enviados en una sola solicitud. Su progreso
+// se calcula a partir del estado de cada trabajo en el JobStore o, si el
+// JobStore ya lo olvidó, del estado final que tenía entonces.
+type Batch struct {
+	ID        string
+	JobIDs    []string
+	Owner     string // Identidad del cliente que envió el lote, o vacía
+	CreatedAt time.Time
+
+	forgotten map[string]JobStatus // Estado final de los trabajos que el JobStore ya olvidó
+}
+
+// BatchProgress es el progreso agregado de un lote.
+type BatchProgress struct {
+	ID        string            `json:"id"`
+	Total     int               `json:"total"`    // Número de trabajos del lote
+	Finished  int               `json:"finished"` // Trabajos en un estado final
+	Progress  float64           `json:"progress"` // Fracción de trabajos terminados, entre 0 y 1
+	Done      bool              `json:"done"`     // Todos los trabajos terminaron
+	Counts    map[JobStatus]int `json:"counts"`   // Trabajos por estado
+	Jobs      []string          `json:"jobs"`     // IDs de los trabajos en el orden enviado
+	Owner     string            `json:"owner,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// BatchStore guarda los lotes enviados. Es seguro para uso concurrente.
+//
+// Registrado con JobStore.ObserveForget, un lote caduca junto con sus
+// trabajos: conserva el estado final de los que el JobStore olvida por
+// Retention y desaparece cuando los olvida todos.
+type BatchStore struct {
+	mu      sync.RWMutex
+	batches map[string]*Batch
+	jobs    map[string]string // Lote de cada trabajo que el JobStore aún no olvidó
+}
+
+// NewBatchStore crea un almacén de lotes vacío.
+func NewBatchStore() *BatchStore {
+	return &BatchStore{
+		batches: make(map[string]*Batch),
+		jobs:    make(map[string]string),
+	}
+}
+
+// Add registra un lote nuevo de owner con los trabajos indicados y lo devuelve.
+func (s *BatchStore) Add(jobIDs []string, owner string) Batch {
+	b := &Batch{ID: NewJobID(), JobIDs: jobIDs, Owner: owner, CreatedAt: time.Now(), forgotten: make(map[string]JobStatus)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[b.ID] = b
+	for _, jobID := range jobIDs {
+		s.jobs[jobID] = b.ID
+	}
+	return *b
+}
+
+// forget se registra con JobStore.ObserveForget. Guarda el estado final de
+// los trabajos olvidados y elimina los lotes que ya no tienen ninguno en el
+// JobStore, de modo que se consultan como si no existieran.
+func (s *BatchStore) forget(recs []JobRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range recs {
+		batchID, ok := s.jobs[rec.ID]
+		if !ok {
+			continue // No pertenece a ningún lote.
+		}
+		delete(s.jobs, rec.ID)
+		b := s.batches[batchID]
+		b.forgotten[rec.ID] = rec.Status
+		if len(b.forgotten) == len(b.JobIDs) {
+			delete(s.batches, batchID)
+		}
+	}
+}
+
+// forgottenStatus devuelve el estado final de un trabajo del lote que el
+// JobStore ya olvidó. El segundo valor es false si el lote ha caducado.
+func (s *BatchStore) forgottenStatus(batchID, jobID string) (JobStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.batches[batchID]
+	if !ok {
+		return "", false
+	}
+	status, ok := b.forgotten[jobID]
+	return status, ok
+}
+
+// Progress calcula el progreso del lote consultando sus trabajos en store,
+// o su estado final si store ya los olvidó. El segundo valor es false si el
+// lote no existe o ha caducado.
+func (s *BatchStore) Progress(id string, store *JobStore) (BatchProgress, bool) {
+	s.mu.RLock()
+	b, ok := s.batches[id]
+	s.mu.RUnlock()
+	if !ok {
+		return BatchProgress{}, false
+	}
+
+	p := BatchProgress{
+		ID:        b.ID,
+		Total:     len(b.JobIDs),
+		Counts:    make(map[JobStatus]int),
+		Jobs:      b.JobIDs,
+		Owner:     b.Owner,
+		CreatedAt: b.CreatedAt,
+	}
+	for _, jobID := range b.JobIDs {
+		rec, ok := store.Get(jobID)
+		status := rec.Status
+		if !ok {
+			if status, ok = s.forgottenStatus(id, jobID); !ok {
+				return BatchProgress{}, false // Caducó mientras se calculaba.
+			}
+		}
+		p.Counts[status]++
+		if status.Terminal() {
+			p.Finished++
+		}
+	}
+	if p.Total > 0 {
+		p.Progress = float64(p.Finished) / float64(p.Total)
+	}
+	p.Done = p.Finished == p.Total
+	return p, true
+}
+
+// EnqueueAll envía todos los trabajos a la cola o ninguno. Se reserva
+// espacio para el lote completo, en la cola o en su backlog, esperando como
+// máximo wait; un lote mayor que Cap() + Backlog() se rechaza de inmediato
+// con ErrQueueFull.
+//
+// Con Coalesce, los trabajos idénticos a uno en curso no ocupan hueco: se
+// unen a su ejecución como en Enqueue. Si el lote se rechaza, el contexto de
+// todos sus trabajos se cancela con el error como causa.
+func (d *Dispatcher) EnqueueAll(jobs []Job, wait time.Duration) error {
+	if err := d.JobQueue.ReserveBatch(len(jobs), wait); err != nil {
+		releaseJobs(jobs, err)
+		return err
+	}
+	return d.accept(jobs)
+}
+
+// BatchItem es el resultado de un elemento de un lote: el ID del trabajo
+// creado o el error que impidió crearlo.
+type BatchItem struct {
+	Index int       `json:"index"`
+	ID    string    `json:"id,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+// BatchResponse es la respuesta a un lote aceptado.
+type BatchResponse struct {
+	ID       string      `json:"id"`
+	Total    int         `json:"total"`
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Items    []BatchItem `json:"items"`
+}
+
+// BatchHandler maneja los lotes de trabajos:
+//   - POST /fibonacci/batch: crea un trabajo por cada elemento del cuerpo,
+//     que puede ser un array JSON (Content-Type: application/json) o un
+//     objeto JSON por línea (Content-Type: application/x-ndjson). Cada
+//     elemento tiene los mismos campos que en POST /fibonacci, salvo wait.
+//     Por defecto el lote es atómico: si algún elemento no es válido se
+//     responde 400 con los errores de cada uno; si el lote no cabe ni con la
+//     cola vacía, 413; y si la cola y su backlog no tienen espacio para todos
+//     tras opts.EnqueueTimeout, 503. En esos casos no se crea ningún trabajo.
+//     Con ?atomic=false se encolan los elementos válidos que quepan en la
+//     cola o en su backlog dentro de opts.EnqueueTimeout y se informa del
+//     error de los demás. Responde 201 con el ID del lote y de cada trabajo.
+//   - GET /fibonacci/batch/{id}: devuelve el progreso agregado del lote.
+func BatchHandler(w http.ResponseWriter, r *http.Request, dispatcher *Dispatcher, store *JobStore, batches *BatchStore, opts RequestOptions) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/fibonacci/batch"), "/")
+	if id != "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, http.MethodGet)
+			return
+		}
+		p, ok := batches.Progress(id, store)
+		if !ok || !visibleTo(r, p.Owner) {
+			writeError(w, http.StatusNotFound, "not_found", "Batch not found", nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	atomic := true
+	if v := r.URL.Query().Get("atomic"); v != "" {
+		atomic = v != "false" && v != "0"
+	}
+
+	raws, err := decodeBatch(w, r, opts.MaxBatch)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedBatch):
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error(), nil)
+		return
+	case errors.Is(err, errBatchTooLarge), errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, "batch_too_large", fmt.Sprintf("Batch must have at most %d jobs", opts.MaxBatch), nil)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body: "+strings.TrimPrefix(err.Error(), "json: "), nil)
+		return
+	case len(raws) == 0:
+		writeError(w, http.StatusBadRequest, "invalid_request", "Batch is empty", nil)
+		return
+	}
+
+	// Valida todos los elementos antes de crear ningún trabajo.
+	jobs := make([]Job, 0, len(raws))
+	indexes := make([]int, 0, len(raws)) // Elemento del lote de cada trabajo
+	items := make([]BatchItem, len(raws))
+	rejected := 0
+	for i, raw := range raws {
+		items[i].Index = i
+		req, errs, err := decodeJobJSON(bytes.NewReader(raw))
+		if err != nil {
+			items[i].Error = &APIError{Code: "invalid_json", Message: err.Error()}
+			rejected++
+			continue
+		}
+		if req.Wait != "" {
+			errs.Add("wait", "is not supported in batches")
+		}
+		job, _, ok := req.Job(opts, dispatcher.RetryPolicy, errs)
+		if !ok {
+			items[i].Error = &APIError{Code: "invalid_request", Message: "Invalid job request", Fields: errs}
+			rejected++
+			continue
+		}
+		job.Owner = requestOwner(r)
+		jobs = append(jobs, job)
+		indexes = append(indexes, i)
+	}
+
+	if atomic {
+		if rejected > 0 {
+			releaseJobs(jobs, errBatchRejected)
+			writeJSON(w, http.StatusBadRequest, struct {
+				Error APIError `json:"error"`
+			}{APIError{
+				Code:    "invalid_request",
+				Message: fmt.Sprintf("%d of %d jobs are invalid, none was accepted", rejected, len(raws)),
+				Items:   failedItems(items),
+			}})
+			return
+		}
+		if limit := dispatcher.JobQueue.Cap() + dispatcher.JobQueue.Backlog(); len(jobs) > limit {
+			releaseJobs(jobs, errBatchTooLarge)
+			writeError(w, http.StatusRequestEntityTooLarge, "batch_too_large", fmt.Sprintf("Atomic batch must have at most %d jobs", limit), nil)
+			return
+		}
+		if err := dispatcher.EnqueueAll(jobs, opts.EnqueueTimeout); err != nil {
+			writeEnqueueError(w, dispatcher, jobs[0], err)
+			return
+		}
+		for k, job := range jobs {
+			items[indexes[k]].ID = job.ID
+		}
+	} else {
+		status, code := http.StatusBadRequest, "invalid_request"
+		deadline := time.Now().Add(opts.EnqueueTimeout) // La espera es para el lote, no para cada elemento.
+		for k, job := range jobs {
+			if err := dispatcher.EnqueueAll([]Job{job}, time.Until(deadline)); err != nil {
+				status, items[indexes[k]].Error = enqueueAPIError(err)
+				code = items[indexes[k]].Error.Code
+				rejected++
+				continue
+			}
+			items[indexes[k]].ID = job.ID
+		}
+		if rejected == len(raws) {
+			writeJSON(w, status, struct {
+				Error APIError `json:"error"`
+			}{APIError{
+				Code:    code,
+				Message: "No job in the batch was accepted",
+				Items:   items,
+			}})
+			return
+		}
+	}
+
+	jobIDs := make([]string, 0, len(items)-rejected)
+	for _, item := range items {
+		if item.ID != "" {
+			jobIDs = append(jobIDs, item.ID)
+		}
+	}
+	batch := batches.Add(jobIDs, requestOwner(r))
+	dispatcher.Logger.Info("batch accepted", "batch_id", batch.ID, "accepted", len(jobIDs), "rejected", rejected)
+
+	w.Header().Set("Location", "/fibonacci/batch/"+batch.ID)
+	writeJSON(w, http.StatusCreated, BatchResponse{
+		ID:       batch.ID,
+		Total:    len(raws),
+		Accepted: len(jobIDs),
+		Rejected: rejected,
+		Items:    items,
+	})
+}
+
+var (
+	// errUnsupportedBatch se devuelve si el cuerpo de un lote no es JSON ni NDJSON.
+	errUnsupportedBatch = errors.New("batch body must be application/json or application/x-ndjson")
+	// errBatchTooLarge se devuelve si el lote supera el número máximo de trabajos.
+	errBatchTooLarge = errors.New("batch too large")
+	// errBatchRejected es la causa con la que se cancelan los trabajos válidos
+	// de un lote atómico que se rechaza por sus elementos inválidos.
+	errBatchRejected = errors.New("batch rejected")
+)
+
+// decodeBatch lee los elementos de un lote sin interpretarlos: un array JSON
+// o un objeto JSON por línea según el Content-Type. Devuelve errBatchTooLarge
+// si hay más de maxItems elementos.
+func decodeBatch(w http.ResponseWriter, r *http.Request, maxItems int) ([]json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := http.MaxBytesReader(w, r.Body, int64(maxItems)*maxJobRequestBytes)
+	dec := json.NewDecoder(body)
+
+	var raws []json.RawMessage
+	switch mediaType {
+	case "application/json":
+		if err := dec.Decode(&raws); err != nil {
+			return nil, err
+		}
+	case "application/x-ndjson":
+		for {
+			var raw json.RawMessage
+			err := dec.Decode(&raw)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			raws = append(raws, raw)
+		}
+	default:
+		return nil, errUnsupportedBatch
+	}
+	if len(raws) > maxItems {
+		return nil, errBatchTooLarge
+	}
+	return raws, nil
+}
+
+// failedItems devuelve los elementos de un lote que tienen error.
+func failedItems(items []BatchItem) []BatchItem {
+	var failed []BatchItem
+	for _, item := range items {
+		if item.Error != nil {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
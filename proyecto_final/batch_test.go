@@ -0,0 +1,236 @@
+// Este archivo contiene pruebas unitarias para el envío de lotes de trabajos.
+// El dispatcher no se arranca: los trabajos aceptados quedan en la cola y las
+// transiciones se simulan directamente sobre el JobStore.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postBatch envía un lote a BatchHandler y devuelve la respuesta.
+func postBatch(t *testing.T, d *Dispatcher, batches *BatchStore, target, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	BatchHandler(rec, req, d, d.Store, batches, RequestOptions{MaxValue: 1000, MaxBatch: 5})
+	return rec
+}
+
+// TestBatchHandlerSubmit verifica la validación y el encolado de un lote en
+// modo atómico y por elementos.
+func TestBatchHandlerSubmit(t *testing.T) {
+	const (
+		valid   = `{"name": "a", "value": 10, "delay": "0s"}`
+		invalid = `{"name": "b", "value": 5000, "delay": "0s"}`
+	)
+	testCases := []struct {
+		name         string
+		target       string
+		contentType  string
+		body         string
+		queueSize    int
+		backlog      int
+		prefill      int // Trabajos encolados antes del lote
+		wantStatus   int
+		wantCode     string
+		wantAccepted int
+		wantQueued   int
+	}{
+		{
+			name:         "json array",
+			target:       "/fibonacci/batch",
+			contentType:  "application/json",
+			body:         "[" + valid + "," + valid + "]",
+			queueSize:    5,
+			wantStatus:   http.StatusCreated,
+			wantAccepted: 2,
+			wantQueued:   2,
+		},
+		{
+			name:         "ndjson",
+			target:       "/fibonacci/batch",
+			contentType:  "application/x-ndjson",
+			body:         valid + "\n" + valid + "\n" + valid + "\n",
+			queueSize:    5,
+			wantStatus:   http.StatusCreated,
+			wantAccepted: 3,
+			wantQueued:   3,
+		},
+		{
+			name:        "atomic with invalid item",
+			target:      "/fibonacci/batch",
+			contentType: "application/json",
+			body:        "[" + valid + "," + invalid + "]",
+			queueSize:   5,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+		},
+		{
+			name:         "atomic into backlog",
+			target:       "/fibonacci/batch",
+			contentType:  "application/json",
+			body:         "[" + valid + "," + valid + "," + valid + "]",
+			queueSize:    2,
+			backlog:      2,
+			wantStatus:   http.StatusCreated,
+			wantAccepted: 3,
+			wantQueued:   3,
+		},
+		{
+			name:        "atomic without room",
+			target:      "/fibonacci/batch",
+			contentType: "application/json",
+			body:        "[" + valid + "," + valid + "," + valid + "]",
+			queueSize:   2,
+			backlog:     2,
+			prefill:     2,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCode:    "queue_full",
+			wantQueued:  2,
+		},
+		{
+			name:        "atomic larger than queue and backlog",
+			target:      "/fibonacci/batch",
+			contentType: "application/json",
+			body:        "[" + valid + "," + valid + "," + valid + "]",
+			queueSize:   2,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "batch_too_large",
+		},
+		{
+			name:         "per item",
+			target:       "/fibonacci/batch?atomic=false",
+			contentType:  "application/json",
+			body:         "[" + valid + "," + invalid + "," + valid + "," + valid + "]",
+			queueSize:    2,
+			wantStatus:   http.StatusCreated,
+			wantAccepted: 2,
+			wantQueued:   2,
+		},
+		{
+			name:         "per item into backlog",
+			target:       "/fibonacci/batch?atomic=false",
+			contentType:  "application/json",
+			body:         "[" + valid + "," + valid + "," + valid + "," + valid + "]",
+			queueSize:    2,
+			backlog:      1,
+			wantStatus:   http.StatusCreated,
+			wantAccepted: 3,
+			wantQueued:   3,
+		},
+		{
+			name:        "too many items",
+			target:      "/fibonacci/batch",
+			contentType: "application/x-ndjson",
+			body:        strings.Repeat(valid+"\n", 6),
+			queueSize:   10,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "batch_too_large",
+		},
+		{
+			name:        "form body",
+			target:      "/fibonacci/batch",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=a&value=10&delay=0s",
+			queueSize:   5,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    "unsupported_media_type",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDispatcher(NewJobQueue(tc.queueSize, tc.backlog, time.Second), 1, NewJobStore(), nil)
+			for i := range tc.prefill {
+				if err := d.Enqueue(NewJob("prefill", 100+i, 0, time.Time{}, PriorityNormal), 0); err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+			}
+			batches := NewBatchStore()
+			rec := postBatch(t, d, batches, tc.target, tc.contentType, tc.body)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if got := d.JobQueue.Len(); got != tc.wantQueued {
+				t.Errorf("JobQueue.Len() = %d; want %d", got, tc.wantQueued)
+			}
+
+			if tc.wantCode != "" {
+				var body struct {
+					Error APIError `json:"error"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+					t.Fatalf("decode error: %v", err)
+				}
+				if body.Error.Code != tc.wantCode {
+					t.Errorf("error code = %q; want %q", body.Error.Code, tc.wantCode)
+				}
+				return
+			}
+
+			var resp BatchResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			if resp.Accepted != tc.wantAccepted || resp.Accepted+resp.Rejected != resp.Total {
+				t.Errorf("accepted, rejected, total = %d, %d, %d; want %d accepted", resp.Accepted, resp.Rejected, resp.Total, tc.wantAccepted)
+			}
+			if loc := rec.Header().Get("Location"); loc != "/fibonacci/batch/"+resp.ID {
+				t.Errorf("Location = %q; want /fibonacci/batch/%s", loc, resp.ID)
+			}
+			for _, item := range resp.Items {
+				if (item.ID == "") == (item.Error == nil) {
+					t.Errorf("item %d = %+v; want either an ID or an error", item.Index, item)
+				}
+			}
+		})
+	}
+}
+
+// TestBatchHandlerProgress verifica que el progreso agregado de un lote
+// refleje el estado de sus trabajos.
+func TestBatchHandlerProgress(t *testing.T) {
+	d := NewDispatcher(NewJobQueue(5, 0, time.Second), 1, NewJobStore(), nil)
+	batches := NewBatchStore()
+	body := `[{"name": "a", "value": 10, "delay": "0s"}, {"name": "b", "value": 20, "delay": "0s"}]`
+	rec := postBatch(t, d, batches, "/fibonacci/batch", "application/json", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d; want %d\n%s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var resp BatchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode batch: %v", err)
+	}
+
+	d.Store.MarkRunning(resp.Items[0].ID, 0)
+	d.Store.MarkDone(resp.Items[0].ID, "55")
+
+	rec = httptest.NewRecorder()
+	BatchHandler(rec, httptest.NewRequest(http.MethodGet, "/fibonacci/batch/"+resp.ID, nil), d, d.Store, batches, RequestOptions{})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	var p BatchProgress
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	if p.Total != 2 || p.Finished != 1 || p.Progress != 0.5 || p.Done {
+		t.Errorf("progress = %+v; want 1 of 2 finished", p)
+	}
+	if p.Counts[StatusDone] != 1 || p.Counts[StatusQueued] != 1 {
+		t.Errorf("counts = %v; want 1 done and 1 queued", p.Counts)
+	}
+
+	rec = httptest.NewRecorder()
+	BatchHandler(rec, httptest.NewRequest(http.MethodGet, "/fibonacci/batch/missing", nil), d, d.Store, batches, RequestOptions{})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing batch status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+}
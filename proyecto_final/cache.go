@@ -0,0 +1,114 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ResultCache es una caché LRU de resultados de Fibonacci compartida por
+// todos los workers. Está acotada tanto en número de entradas como en bytes,
+// ya que los resultados de valores grandes ocupan cientos de kilobytes.
+// Es segura para uso concurrente.
+type ResultCache struct {
+	Hits   Counter // Consultas que encontraron el resultado
+	Misses Counter // Consultas que no lo encontraron
+
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	order      *list.List            // Entradas de la más a la menos usada
+	entries    map[int]*list.Element // Elementos de order por número
+}
+
+// cacheEntry es un resultado almacenado en la caché.
+type cacheEntry struct {
+	number int
+	result string
+}
+
+// NewResultCache crea una caché vacía con los límites indicados.
+//
+// Parámetros:
+//   - maxEntries: Número máximo de resultados almacenados
+//   - maxBytes: Tamaño máximo en bytes de la suma de los resultados
+//
+// Retorna:
+//   - *ResultCache: Nueva caché vacía
+func NewResultCache(maxEntries, maxBytes int) *ResultCache {
+	return &ResultCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		entries:    make(map[int]*list.Element),
+	}
+}
+
+// Get devuelve el resultado almacenado para n y lo marca como el más
+// reciente. El segundo valor es false si no está en la caché.
+func (c *ResultCache) Get(n int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[n]
+	if !ok {
+		c.Misses.Inc()
+		return "", false
+	}
+	c.Hits.Inc()
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).result, true
+}
+
+// Lookup es como Get, pero no cuenta un fallo si el resultado no está en la
+// caché. La usa el RequestHandler para servir resultados sin encolar el
+// trabajo: si falla, el worker vuelve a consultar la caché con Get y el
+// fallo se cuenta una sola vez.
+func (c *ResultCache) Lookup(n int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[n]
+	if !ok {
+		return "", false
+	}
+	c.Hits.Inc()
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).result, true
+}
+
+// Add almacena el resultado de n, expulsando los menos usados recientemente
+// hasta respetar los límites. Un resultado mayor que el límite de bytes no
+// se almacena.
+func (c *ResultCache) Add(n int, result string) {
+	if len(result) > c.maxBytes || c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[n]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[n] = c.order.PushFront(&cacheEntry{number: n, result: result})
+	c.bytes += len(result)
+
+	for c.order.Len() > c.maxEntries || c.bytes > c.maxBytes {
+		oldest := c.order.Remove(c.order.Back()).(*cacheEntry)
+		delete(c.entries, oldest.number)
+		c.bytes -= len(oldest.result)
+	}
+}
+
+// Len devuelve el número de resultados almacenados.
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Bytes devuelve el tamaño en bytes de los resultados almacenados.
+func (c *ResultCache) Bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
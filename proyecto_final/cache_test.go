@@ -0,0 +1,123 @@
+// Este archivo contiene pruebas unitarias para la caché LRU de resultados.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResultCacheEviction verifica que la caché expulse las entradas menos
+// usadas recientemente al superar el límite de entradas o de bytes.
+//
+// En cada caso se añaden 10, 11 y 12, consultando 10 antes de añadir 12,
+// de modo que la entrada menos usada recientemente es 11.
+func TestResultCacheEviction(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+	}{
+		{name: "entry limit", maxEntries: 2, maxBytes: 100},
+		{name: "byte limit", maxEntries: 10, maxBytes: 6},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := NewResultCache(tc.maxEntries, tc.maxBytes)
+			cache.Add(10, "55")
+			cache.Add(11, "89")
+			cache.Get(10)
+			cache.Add(12, "144")
+
+			for _, n := range []int{10, 12} {
+				if _, ok := cache.Get(n); !ok {
+					t.Errorf("Get(%d) missing; want cached", n)
+				}
+			}
+			if _, ok := cache.Get(11); ok {
+				t.Error("Get(11) found; want evicted")
+			}
+			if cache.Len() != 2 || cache.Bytes() != 5 {
+				t.Errorf("Len(), Bytes() = %d, %d; want 2, 5", cache.Len(), cache.Bytes())
+			}
+		})
+	}
+}
+
+// TestResultCacheTooLarge verifica que no se almacenen resultados mayores
+// que el límite de bytes.
+func TestResultCacheTooLarge(t *testing.T) {
+	cache := NewResultCache(10, 4)
+	cache.Add(20, "6765")
+	cache.Add(30, "832040")
+	if _, ok := cache.Get(30); ok {
+		t.Error("Get(30) found; want result larger than the byte limit skipped")
+	}
+	if got, ok := cache.Get(20); !ok || got != "6765" {
+		t.Errorf("Get(20) = %q, %v; want \"6765\", true", got, ok)
+	}
+	if hits, misses := cache.Hits.Value(), cache.Misses.Value(); hits != 1 || misses != 1 {
+		t.Errorf("Hits, Misses = %d, %d; want 1, 1", hits, misses)
+	}
+}
+
+// TestWorkerUsesCache verifica que el worker guarde los resultados que
+// calcula y reutilice los que ya están en la caché.
+func TestWorkerUsesCache(t *testing.T) {
+	cache := NewResultCache(10, 1<<10)
+	worker := NewWorker(0, nil, NewJobStore(), nil, nil)
+	worker.Cache = cache
+
+	for range 2 {
+		got, err := worker.fibonacci(context.Background(), 50)
+		if err != nil || got != "12586269025" {
+			t.Fatalf("fibonacci(50) = %q, %v; want \"12586269025\", nil", got, err)
+		}
+	}
+	if hits, misses := cache.Hits.Value(), cache.Misses.Value(); hits != 1 || misses != 1 {
+		t.Errorf("Hits, Misses = %d, %d; want 1, 1", hits, misses)
+	}
+}
+
+// TestRequestHandlerServesCached verifica que con ServeCached un acierto de
+// caché se responda de inmediato sin encolar el trabajo.
+func TestRequestHandlerServesCached(t *testing.T) {
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(5, 0, time.Second), 1, store, nil)
+	dispatcher.Cache = NewResultCache(10, 1<<10)
+	dispatcher.Cache.Add(10, "55")
+	opts := RequestOptions{MaxValue: 100, ServeCached: true}
+
+	testCases := []struct {
+		value      string
+		wantStatus int
+		wantCache  string
+	}{
+		{value: "10", wantStatus: http.StatusOK, wantCache: "HIT"},
+		{value: "11", wantStatus: http.StatusCreated, wantCache: ""},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/fibonacci", strings.NewReader("name=test&delay=1s&value="+tc.value))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		RequestHandler(rec, req, dispatcher, store, opts)
+
+		if rec.Code != tc.wantStatus {
+			t.Errorf("value=%s: status = %d; want %d", tc.value, rec.Code, tc.wantStatus)
+		}
+		if got := rec.Header().Get("X-Cache"); got != tc.wantCache {
+			t.Errorf("value=%s: X-Cache = %q; want %q", tc.value, got, tc.wantCache)
+		}
+	}
+	if got := dispatcher.JobQueue.Len(); got != 1 {
+		t.Errorf("JobQueue.Len() = %d; want 1 (only the cache miss)", got)
+	}
+	// El fallo se cuenta cuando el worker consulta la caché, no en el handler.
+	if hits, misses := dispatcher.Cache.Hits.Value(), dispatcher.Cache.Misses.Value(); hits != 1 || misses != 0 {
+		t.Errorf("Hits, Misses = %d, %d; want 1, 0", hits, misses)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// flightKey identifica la entrada de un trabajo y cómo se ejecuta: dos
+// trabajos con la misma clave producen el mismo resultado con la misma
+// prioridad y la misma política de reintentos, de modo que un seguidor nunca
+// espera detrás de un líder menos prioritario ni hereda reintentos ajenos.
+type flightKey struct {
+	number   int
+	delay    time.Duration
+	priority Priority
+	retry    RetryPolicy
+}
+
+// flight es una ejecución en curso compartida por varios trabajos idénticos.
+// El líder es el único que pasa por el JobQueue y los workers; los
+// seguidores reciben su resultado al terminar.
+type flight struct {
+	key       flightKey
+	leader    Job
+	followers []Job
+	stranded  bool // El líder promovido no pudo encolarse
+}
+
+// joinFlight une el trabajo a la ejecución en curso de un trabajo idéntico y
+// devuelve true, en cuyo caso no debe encolarse. Si no hay ninguna, el
+// trabajo pasa a liderar una nueva ejecución y devuelve false.
+func (d *Dispatcher) joinFlight(job Job) bool {
+	if !d.Coalesce {
+		return false
+	}
+	key := flightKey{number: job.Number, delay: job.Delay, priority: job.Priority, retry: job.Retry}
+
+	d.flightMu.Lock()
+	fl, ok := d.flights[key]
+	if !ok {
+		fl = &flight{key: key, leader: job}
+		d.flights[key] = fl
+		d.leaders[job.ID] = fl
+		d.flightMu.Unlock()
+		return false
+	}
+	fl.followers = append(fl.followers, job)
+	leader := fl.leader
+	d.flightMu.Unlock()
+
+	d.Store.SetCoalescedWith(job.ID, leader.ID)
+	d.Coalesced.Inc()
+	d.Logger.Info("job coalesced", append(jobAttrs(job), "leader_id", leader.ID)...)
+	return true
+}
+
+// observeFlight se registra con JobStore.Observe. Cuando el líder de una
+// ejecución termina, entrega su resultado a los seguidores o, si falló, los
+// pasa por handleFailure con los intentos del líder, de modo que acaban en la
+// cola de mensajes muertos como él; si se canceló o expiró, uno de los
+// seguidores pasa a liderar la ejecución.
+func (d *Dispatcher) observeFlight(rec JobRecord) {
+	if !rec.Status.Terminal() {
+		return
+	}
+	d.flightMu.Lock()
+	fl, ok := d.leaders[rec.ID]
+	if !ok {
+		d.flightMu.Unlock()
+		return // No lidera ninguna ejecución.
+	}
+	delete(d.leaders, rec.ID)
+
+	if rec.Status != StatusDone && rec.Status != StatusFailed {
+		leader, followers, ok := d.nextLeader(fl)
+		d.flightMu.Unlock()
+		if ok {
+			d.promote(fl, leader, followers)
+		}
+		return
+	}
+	delete(d.flights, fl.key)
+	followers := fl.followers
+	d.flightMu.Unlock()
+
+	for _, follower := range followers {
+		if rec.Status == StatusDone {
+			d.Store.MarkDone(follower.ID, *rec.Result)
+			continue
+		}
+		follower.Attempt = max(follower.Attempt, rec.Attempts)
+		d.handleFailure(follower, errors.New(rec.Error))
+	}
+}
+
+// nextLeader elige como nuevo líder al primer seguidor vigente de la
+// ejecución y devuelve también los seguidores restantes. Si no queda
+// ninguno, la ejecución desaparece y el tercer valor es false.
+// Debe llamarse con flightMu tomado.
+func (d *Dispatcher) nextLeader(fl *flight) (Job, []Job, bool) {
+	for i, follower := range fl.followers {
+		if follower.Context().Err() == nil {
+			fl.leader, fl.followers = follower, fl.followers[i+1:]
+			d.leaders[follower.ID] = fl
+			return fl.leader, fl.followers, true
+		}
+	}
+	delete(d.flights, fl.key)
+	return Job{}, nil, false
+}
+
+// promote actualiza los registros tras elegir un nuevo líder de fl y lo
+// encola, esperando a que haya espacio en la cola si es necesario. Si no
+// puede encolarse porque el dispatcher se está deteniendo, la ejecución
+// queda varada y abandonFlights informa también del líder.
+func (d *Dispatcher) promote(fl *flight, leader Job, followers []Job) {
+	d.Store.SetCoalescedWith(leader.ID, "")
+	for _, follower := range followers {
+		d.Store.SetCoalescedWith(follower.ID, leader.ID)
+	}
+	d.Logger.Info("coalesced job promoted", jobAttrs(leader)...)
+
+	d.promoting.Add(1)
+	go func() {
+		defer d.promoting.Done()
+		err := d.JobQueue.Push(leader, requeueWait)
+		for errors.Is(err, ErrQueueFull) {
+			err = d.JobQueue.Push(leader, requeueWait)
+		}
+		if err != nil {
+			d.Logger.Error("could not enqueue promoted job", append(jobAttrs(leader), logError, err)...)
+			d.flightMu.Lock()
+			fl.stranded = true
+			d.flightMu.Unlock()
+		}
+	}()
+}
+
+// abandonFlights devuelve los seguidores vigentes de las ejecuciones que no
+// llegaron a terminar, y los líderes promovidos que no llegaron a encolarse,
+// para informar de ellos al detener el dispatcher.
+func (d *Dispatcher) abandonFlights() []Job {
+	d.flightMu.Lock()
+	defer d.flightMu.Unlock()
+	var jobs []Job
+	for _, fl := range d.flights {
+		if fl.stranded && fl.leader.Context().Err() == nil {
+			jobs = append(jobs, fl.leader)
+		}
+		for _, follower := range fl.followers {
+			if follower.Context().Err() == nil {
+				jobs = append(jobs, follower)
+			}
+		}
+	}
+	return jobs
+}
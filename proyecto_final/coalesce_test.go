@@ -0,0 +1,172 @@
+// Este archivo contiene pruebas unitarias para la ejecución compartida de
+// trabajos idénticos. El dispatcher no se arranca: las transiciones del líder
+// se simulan directamente sobre el JobStore.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newCoalescingDispatcher crea un dispatcher con Coalesce activo sin workers.
+func newCoalescingDispatcher() *Dispatcher {
+	d := NewDispatcher(NewJobQueue(10, 0, time.Second), 1, NewJobStore(), nil)
+	d.Coalesce = true
+	d.Store.Observe(d.observeFlight)
+	return d
+}
+
+// enqueueJobs registra y encola trabajos con el número y el delay indicados.
+func enqueueJobs(t *testing.T, d *Dispatcher, n int, number int, delay time.Duration) []Job {
+	t.Helper()
+	jobs := make([]Job, n)
+	for i := range jobs {
+		jobs[i] = NewJob("job", number, delay, time.Time{}, PriorityNormal)
+		if err := d.Enqueue(jobs[i], 0); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	return jobs
+}
+
+// TestCoalesceSharesResult verifica que solo el primero de varios trabajos
+// idénticos se encole y que todos reciban su resultado.
+func TestCoalesceSharesResult(t *testing.T) {
+	testCases := []struct {
+		name       string
+		finish     func(s *JobStore, id string)
+		wantStatus JobStatus
+		wantDead   bool // Los seguidores acaban en la cola de mensajes muertos
+	}{
+		{
+			name:       "done",
+			finish:     func(s *JobStore, id string) { s.MarkDone(id, "102334155") },
+			wantStatus: StatusDone,
+		},
+		{
+			name:       "failed",
+			finish:     func(s *JobStore, id string) { s.MarkFailed(id, "boom") },
+			wantStatus: StatusFailed,
+			wantDead:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newCoalescingDispatcher()
+			jobs := enqueueJobs(t, d, 3, 40, 0)
+			enqueueJobs(t, d, 1, 40, time.Second) // Distinto delay, no se comparte.
+
+			if got := d.JobQueue.Len(); got != 2 {
+				t.Fatalf("JobQueue.Len() = %d; want 2", got)
+			}
+			if got := d.Coalesced.Value(); got != 2 {
+				t.Errorf("Coalesced = %d; want 2", got)
+			}
+			for _, job := range jobs[1:] {
+				if rec, _ := d.Store.Get(job.ID); rec.CoalescedWith != jobs[0].ID {
+					t.Errorf("follower coalesced_with = %q; want %q", rec.CoalescedWith, jobs[0].ID)
+				}
+			}
+
+			d.Store.MarkRunning(jobs[0].ID, 0)
+			tc.finish(d.Store, jobs[0].ID)
+
+			leader, _ := d.Store.Get(jobs[0].ID)
+			for _, job := range jobs[1:] {
+				rec, _ := d.Store.Get(job.ID)
+				if rec.Status != tc.wantStatus {
+					t.Errorf("follower status = %s; want %s", rec.Status, tc.wantStatus)
+				}
+				if (rec.Result == nil) != (leader.Result == nil) || rec.Error != leader.Error {
+					t.Errorf("follower result, error = %v, %q; want leader's", rec.Result, rec.Error)
+				}
+				if _, dead := d.DeadLetters.Get(job.ID); dead != tc.wantDead {
+					t.Errorf("follower dead-lettered = %v; want %v", dead, tc.wantDead)
+				}
+			}
+
+			enqueueJobs(t, d, 1, 40, 0) // La ejecución terminó; este trabajo se encola.
+			if got := d.JobQueue.Len(); got != 3 {
+				t.Errorf("JobQueue.Len() after finish = %d; want 3", got)
+			}
+		})
+	}
+}
+
+// TestCoalesceRequiresSameExecution verifica que no se compartan ejecuciones
+// entre trabajos con la misma entrada pero distinta prioridad o distinta
+// política de reintentos.
+func TestCoalesceRequiresSameExecution(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(job *Job)
+	}{
+		{
+			name:   "higher priority",
+			modify: func(job *Job) { job.Priority = PriorityHigh },
+		},
+		{
+			name:   "other retry policy",
+			modify: func(job *Job) { job.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute} },
+		},
+		{
+			name:   "other attempt timeout",
+			modify: func(job *Job) { job.Retry.AttemptTimeout = time.Second },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newCoalescingDispatcher()
+			leader := enqueueJobs(t, d, 1, 40, 0)[0]
+			job := NewJob("job", 40, 0, time.Time{}, PriorityNormal)
+			tc.modify(&job)
+			if err := d.Enqueue(job, 0); err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+
+			if got := d.JobQueue.Len(); got != 2 {
+				t.Errorf("JobQueue.Len() = %d; want 2", got)
+			}
+			if got := d.Coalesced.Value(); got != 0 {
+				t.Errorf("Coalesced = %d; want 0", got)
+			}
+			if rec, _ := d.Store.Get(job.ID); rec.CoalescedWith != "" {
+				t.Errorf("coalesced_with = %q; want empty (leader %s)", rec.CoalescedWith, leader.ID)
+			}
+		})
+	}
+}
+
+// TestCoalescePromotesFollower verifica que, si el líder se cancela, el
+// primer seguidor vigente se encola y lidera a los demás.
+func TestCoalescePromotesFollower(t *testing.T) {
+	d := newCoalescingDispatcher()
+	jobs := enqueueJobs(t, d, 4, 40, 0)
+
+	if _, err := d.Store.Cancel(jobs[1].ID); err != nil { // Un seguidor cancelado no puede liderar.
+		t.Fatalf("Cancel(follower) error = %v", err)
+	}
+	if _, err := d.Store.Cancel(jobs[0].ID); err != nil {
+		t.Fatalf("Cancel(leader) error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for d.JobQueue.Len() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond) // El nuevo líder se encola en segundo plano.
+	}
+	if got := d.JobQueue.Len(); got != 1 {
+		t.Fatalf("JobQueue.Len() = %d; want 1 (promoted follower)", got)
+	}
+	if rec, _ := d.Store.Get(jobs[2].ID); rec.CoalescedWith != "" {
+		t.Errorf("promoted coalesced_with = %q; want empty", rec.CoalescedWith)
+	}
+	if rec, _ := d.Store.Get(jobs[3].ID); rec.CoalescedWith != jobs[2].ID {
+		t.Errorf("follower coalesced_with = %q; want %q", rec.CoalescedWith, jobs[2].ID)
+	}
+
+	d.Store.MarkDone(jobs[2].ID, "102334155")
+	if rec, _ := d.Store.Get(jobs[3].ID); rec.Status != StatusDone {
+		t.Errorf("follower status = %s; want %s", rec.Status, StatusDone)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config reúne la configuración del servidor. Cada opción se puede indicar,
+// de mayor a menor prioridad, con un flag (-max-workers=8), con una variable
+// de entorno (FIBONACCI_MAX_WORKERS=8) o con el archivo indicado por -config
+// o FIBONACCI_CONFIG (max-workers: 8); si no, toma su valor por defecto.
+type Config struct {
+	Addr            string        // Dirección en la que escucha el servidor
+	ShutdownTimeout time.Duration // Plazo para cerrar las solicitudes, y otro para drenar la cola, al parar
+	ShutdownDelay   time.Duration // Tiempo que /readyz falla antes de cerrar el servidor al parar
+	StuckAfter      time.Duration // Tiempo con un mismo trabajo tras el que un worker se considera atascado
+
+	MaxWorkers     int           // Workers máximos del pool
+	MinWorkers     int           // Workers mínimos; por debajo de MaxWorkers activa el autoescalado
+	ScaleInterval  time.Duration // Cada cuánto revisa la cola el autoescalado
+	ScaleBacklog   int           // Trabajos en cola por worker que hacen crecer el pool
+	ScaleQueueWait time.Duration // Espera en cola que hace crecer el pool
+	ScaleCooldown  time.Duration // Tiempo sin cola antes de retirar workers ociosos
+
+	QueueSize      int           // Trabajos que admite la cola
+	QueueBacklog   int           // Trabajos de lotes que admite la cola además de QueueSize
+	PriorityAging  time.Duration // Espera que compensa un nivel de prioridad
+	EnqueueTimeout time.Duration // Espera máxima de una solicitud con la cola llena
+	MaxValue       int           // Mayor número de Fibonacci aceptado
+	MaxBatch       int           // Trabajos máximos de un lote
+	JobRetention   time.Duration // Tiempo que se conservan los trabajos terminados, cero para siempre
+
+	RateLimit          float64       // Solicitudes por segundo de cada cliente, cero para no limitar
+	RateBurst          int           // Ráfaga máxima de solicitudes de cada cliente
+	RateLimitIdle      time.Duration // Tiempo sin solicitudes tras el que se olvida a un cliente
+	RateLimitOverrides string        // Límites propios por cliente, ver ParseRateLimits
+
+	APIKeys string // Archivo JSON de API keys, vacío para no exigir autenticación
+
+	TLSCert           string        // Certificado del servidor en PEM, vacío para servir HTTP sin TLS
+	TLSKey            string        // Clave privada del certificado del servidor
+	TLSClientCA       string        // CA de los certificados de cliente; activa mTLS
+	TLSReloadInterval time.Duration // Cada cuánto se buscan cambios en el certificado
+
+	MaxAttempts     int           // Intentos por defecto de cada trabajo
+	AttemptTimeout  time.Duration // Duración máxima por defecto de cada intento
+	RetryBackoff    time.Duration // Espera base por defecto entre reintentos
+	RetryMaxBackoff time.Duration // Espera máxima entre reintentos
+
+	DeadLetterCapacity int           // Trabajos máximos en la cola de mensajes muertos, cero sin límite
+	DeadLetterTTL      time.Duration // Tiempo que se conserva cada mensaje muerto, cero para siempre
+
+	WALPath            string        // Ruta del write-ahead log, vacía para desactivarlo
+	WALCompactInterval time.Duration // Cada cuánto se compacta el write-ahead log
+
+	CacheEntries int  // Resultados máximos en la caché, cero para desactivarla
+	CacheBytes   int  // Tamaño máximo de la caché en bytes
+	Coalesce     bool // Compartir la ejecución de trabajos idénticos
+	ServeCached  bool // Responder sin encolar si el resultado está en caché
+
+	WebhookSecret   string        // Clave de firma de los callbacks, vacía para desactivarlos
+	WebhookAttempts int           // Intentos de entrega de cada callback
+	WebhookBackoff  time.Duration // Espera base entre intentos de entrega
+	WebhookTimeout  time.Duration // Duración máxima de cada intento de entrega
+	WebhookNetworks string        // Redes no públicas a las que se permite entregar callbacks
+	EventBuffer     int           // Eventos pendientes por stream antes de desconectarlo
+
+	LogFormat string // "text" o "json"
+	LogLevel  string // "debug", "info", "warn" o "error"
+
+	File string // Archivo de configuración leído, o vacío
+
+	flags *flag.FlagSet
+}
+
+// envPrefix es el prefijo de las variables de entorno de la configuración.
+const envPrefix = "FIBONACCI_"
+
+// secretSettings son las opciones cuyo valor no se muestra en los logs.
+var secretSettings = map[string]bool{"webhook-secret": true}
+
+// registerFlags define en fs una opción por cada campo de la configuración,
+// con su valor por defecto.
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.File, "config", "", "path of a YAML or JSON configuration file")
+	fs.StringVar(&c.Addr, "addr", ":8081", "address the HTTP server listens on")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "maximum time to close HTTP requests, and then to drain queued jobs, on shutdown")
+	fs.DurationVar(&c.ShutdownDelay, "shutdown-delay", 0, "time /readyz reports not ready on shutdown before the server stops accepting connections")
+	fs.DurationVar(&c.StuckAfter, "stuck-after", time.Minute, "time on the same job after which a worker counts as stuck for /readyz")
+
+	fs.IntVar(&c.MaxWorkers, "max-workers", 4, "maximum number of workers in the pool")
+	fs.IntVar(&c.MinWorkers, "min-workers", 0, "minimum number of workers; below -max-workers enables autoscaling (0 means fixed at -max-workers)")
+	fs.DurationVar(&c.ScaleInterval, "scale-interval", time.Second, "how often the autoscaler checks the job queue")
+	fs.IntVar(&c.ScaleBacklog, "scale-backlog", 2, "queued jobs per worker that make the autoscaler add workers")
+	fs.DurationVar(&c.ScaleQueueWait, "scale-queue-wait", 2*time.Second, "queue wait that makes the autoscaler add workers")
+	fs.DurationVar(&c.ScaleCooldown, "scale-cooldown", 30*time.Second, "time without queued jobs before the autoscaler retires idle workers")
+
+	fs.IntVar(&c.QueueSize, "queue-size", 20, "maximum number of queued jobs")
+	fs.IntVar(&c.QueueBacklog, "queue-backlog", 1000, "additional queued jobs admitted from batches; an atomic batch may have up to queue-size plus this")
+	fs.DurationVar(&c.PriorityAging, "priority-aging", 10*time.Second, "waiting time that makes up for one priority level")
+	fs.DurationVar(&c.EnqueueTimeout, "enqueue-timeout", 0, "maximum time a request waits for room in a full job queue")
+	fs.IntVar(&c.MaxValue, "max-value", 500_000, "largest value accepted for a Fibonacci job")
+	fs.IntVar(&c.MaxBatch, "max-batch", 1000, "maximum number of jobs in a batch request")
+	fs.DurationVar(&c.JobRetention, "job-retention", time.Hour, "time finished jobs stay queryable before they are dropped (0 keeps them forever)")
+
+	fs.Float64Var(&c.RateLimit, "rate-limit", 0, "job requests per second allowed to each client (0 disables rate limiting)")
+	fs.IntVar(&c.RateBurst, "rate-burst", 10, "job requests a client may send in a burst")
+	fs.DurationVar(&c.RateLimitIdle, "rate-limit-idle", 10*time.Minute, "time without requests after which a client's rate limit state is dropped")
+	fs.StringVar(&c.RateLimitOverrides, "rate-limit-overrides", "", "per-client limits as key=rate:burst, comma separated (e.g. key:alice=20:40,ip:10.0.0.7=1:2)")
+
+	fs.StringVar(&c.APIKeys, "api-keys", "", "path of a JSON file with hashed API keys and their scopes (empty disables authentication)")
+
+	fs.StringVar(&c.TLSCert, "tls-cert", "", "path of the PEM server certificate chain (empty serves plain HTTP)")
+	fs.StringVar(&c.TLSKey, "tls-key", "", "path of the PEM private key of -tls-cert")
+	fs.StringVar(&c.TLSClientCA, "tls-client-ca", "", "path of PEM CA certificates for client certificates; enables mutual TLS")
+	fs.DurationVar(&c.TLSReloadInterval, "tls-reload-interval", 30*time.Second, "how often the server certificate files are checked for changes")
+
+	fs.IntVar(&c.MaxAttempts, "max-attempts", 3, "default maximum attempts per job, including the first one")
+	fs.DurationVar(&c.AttemptTimeout, "attempt-timeout", 0, "default maximum duration of each attempt (0 means no limit)")
+	fs.DurationVar(&c.RetryBackoff, "retry-backoff", time.Second, "default base wait before retrying a failed job")
+	fs.DurationVar(&c.RetryMaxBackoff, "retry-max-backoff", 30*time.Second, "maximum wait between retries")
+	fs.IntVar(&c.DeadLetterCapacity, "dead-letter-capacity", 1000, "maximum number of dead letters; the oldest is dropped to make room (0 means no limit)")
+	fs.DurationVar(&c.DeadLetterTTL, "dead-letter-ttl", 24*time.Hour, "time a dead letter is kept before it is dropped (0 keeps them forever)")
+
+	fs.StringVar(&c.WALPath, "wal", "fibonacci.wal", "path of the write-ahead log of accepted jobs (empty disables it)")
+	fs.DurationVar(&c.WALCompactInterval, "wal-compact-interval", time.Minute, "how often the write-ahead log is compacted")
+
+	fs.IntVar(&c.CacheEntries, "cache-entries", 1000, "maximum number of cached Fibonacci results (0 disables the cache)")
+	fs.IntVar(&c.CacheBytes, "cache-bytes", 64<<20, "maximum total size in bytes of cached Fibonacci results")
+	fs.BoolVar(&c.Coalesce, "coalesce", true, "share a single execution between identical in-flight jobs")
+	fs.BoolVar(&c.ServeCached, "serve-cached", false, "answer requests whose result is cached without queueing a job")
+
+	fs.StringVar(&c.WebhookSecret, "webhook-secret", "", "key used to sign job callbacks with HMAC-SHA256 (empty disables callback_url)")
+	fs.IntVar(&c.WebhookAttempts, "webhook-max-attempts", 5, "maximum delivery attempts per job callback")
+	fs.DurationVar(&c.WebhookBackoff, "webhook-backoff", time.Second, "base wait before retrying a failed callback delivery")
+	fs.DurationVar(&c.WebhookTimeout, "webhook-timeout", 10*time.Second, "maximum duration of each callback delivery attempt")
+	fs.StringVar(&c.WebhookNetworks, "webhook-allow-networks", "", "non-public networks callbacks may reach, as comma-separated CIDRs (e.g. 10.0.0.0/8); other loopback, private and link-local addresses are blocked")
+	fs.IntVar(&c.EventBuffer, "event-buffer", 64, "events buffered per event stream before a slow subscriber is dropped")
+
+	fs.StringVar(&c.LogFormat, "log-format", "text", "log output format: text or json")
+	fs.StringVar(&c.LogLevel, "log-level", "info", "minimum log level: debug, info, warn or error")
+}
+
+// LoadConfig construye la configuración a partir de los argumentos de la
+// línea de comandos, las variables de entorno y el archivo de configuración,
+// y la valida.
+//
+// Parámetros:
+//   - name: Nombre del programa, usado en la ayuda de los flags
+//   - args: Argumentos de la línea de comandos, sin el nombre del programa
+//   - lookupEnv: Función que lee las variables de entorno, normalmente os.LookupEnv
+//
+// Retorna:
+//   - *Config: Configuración efectiva
+//   - error: flag.ErrHelp si se pidió la ayuda, o todos los errores encontrados
+func LoadConfig(name string, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := &Config{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	c.registerFlags(fs)
+	c.flags = fs
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	explicit := make(map[string]bool) // Opciones indicadas con flags.
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if !explicit["config"] {
+		if path, ok := lookupEnv(envName("config")); ok {
+			c.File = path
+		}
+	}
+	var file map[string]string
+	if c.File != "" {
+		var err error
+		if file, err = readConfigFile(c.File); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for key := range file {
+		if key == "config" || fs.Lookup(key) == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", c.File, key))
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || f.Name == "config" {
+			return
+		}
+		if value, ok := lookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q", envName(f.Name), value))
+			}
+			return
+		}
+		if value, ok := file[f.Name]; ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: invalid value %q", c.File, f.Name, value))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// envName devuelve la variable de entorno de una opción: "max-workers" se
+// lee de FIBONACCI_MAX_WORKERS.
+func envName(setting string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// Validate comprueba que la configuración sea coherente y devuelve todos los
+// problemas encontrados a la vez.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, msg string) {
+		if !ok {
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	check(c.Addr != "", "addr must not be empty")
+	check(c.MaxWorkers >= 1, "max-workers must be at least 1")
+	check(c.MinWorkers >= 0 && c.MinWorkers <= c.MaxWorkers, "min-workers must be between 0 and max-workers")
+	check(c.ScaleBacklog >= 1 && c.ScaleInterval > 0, "scale-backlog and scale-interval must be positive")
+	check(c.QueueSize >= 1, "queue-size must be at least 1")
+	check(c.QueueBacklog >= 0, "queue-backlog must not be negative")
+	check(c.MaxBatch >= 1, "max-batch must be at least 1")
+	check(c.EventBuffer >= 1, "event-buffer must be at least 1")
+	check(c.MaxValue >= 0, "max-value must not be negative")
+	check(c.MaxAttempts >= 1, "max-attempts must be at least 1")
+	check(c.DeadLetterCapacity >= 0, "dead-letter-capacity must not be negative")
+	check(c.WebhookAttempts >= 1, "webhook-max-attempts must be at least 1")
+	check(c.CacheEntries >= 0 && c.CacheBytes >= 0, "cache-entries and cache-bytes must not be negative")
+	check(c.WALPath == "" || c.WALCompactInterval > 0, "wal-compact-interval must be positive")
+	check(c.ShutdownTimeout > 0, "shutdown-timeout must be positive")
+	check(c.PriorityAging > 0, "priority-aging must be positive")
+	check(c.StuckAfter > 0, "stuck-after must be positive")
+	check(c.RateLimit >= 0, "rate-limit must not be negative")
+	check(c.RateLimit == 0 || c.RateBurst >= 1 && c.RateLimitIdle > 0, "rate-burst must be at least 1 and rate-limit-idle positive")
+	if _, err := ParseRateLimits(c.RateLimitOverrides); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseNetworks(c.WebhookNetworks); err != nil {
+		errs = append(errs, err)
+	}
+	check((c.TLSCert == "") == (c.TLSKey == ""), "tls-cert and tls-key must be set together")
+	check(c.TLSClientCA == "" || c.TLSCert != "", "tls-client-ca requires tls-cert and tls-key")
+	check(c.TLSClientCA == "" || c.APIKeys != "", "tls-client-ca requires api-keys to give client certificates an identity")
+	check(c.TLSReloadInterval >= 0, "tls-reload-interval must not be negative")
+	for name, d := range map[string]time.Duration{
+		"enqueue-timeout":   c.EnqueueTimeout,
+		"job-retention":     c.JobRetention,
+		"attempt-timeout":   c.AttemptTimeout,
+		"retry-backoff":     c.RetryBackoff,
+		"retry-max-backoff": c.RetryMaxBackoff,
+		"dead-letter-ttl":   c.DeadLetterTTL,
+		"webhook-backoff":   c.WebhookBackoff,
+		"webhook-timeout":   c.WebhookTimeout,
+		"scale-queue-wait":  c.ScaleQueueWait,
+		"scale-cooldown":    c.ScaleCooldown,
+		"shutdown-delay":    c.ShutdownDelay,
+	} {
+		check(d >= 0, name+" must not be negative")
+	}
+	if _, err := NewLogger(io.Discard, c.LogFormat, c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// LogValue presenta la configuración efectiva en los logs, con una entrada
+// por opción y los secretos ocultos.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	if c.flags != nil {
+		c.flags.VisitAll(func(f *flag.Flag) {
+			value := f.Value.String()
+			if secretSettings[f.Name] && value != "" {
+				value = "<redacted>"
+			}
+			attrs = append(attrs, slog.String(f.Name, value))
+		})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// readConfigFile lee un archivo de configuración y devuelve el valor de cada
+// opción como texto, tal como se escribiría en su flag. El formato se elige
+// por la extensión: .json, o .yaml y .yml.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return parseJSONConfig(path, data)
+	case ".yaml", ".yml":
+		return parseYAMLConfig(path, data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file extension %q (want .json, .yaml or .yml)", path, ext)
+	}
+}
+
+// parseJSONConfig lee un objeto JSON plano cuyos valores son textos,
+// números o booleanos.
+func parseJSONConfig(path string, data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			settings[key] = v
+		case json.Number:
+			settings[key] = v.String()
+		case bool:
+			settings[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, key)
+		}
+	}
+	return settings, nil
+}
+
+// parseYAMLConfig lee el subconjunto de YAML que necesita la configuración:
+// una línea "clave: valor" por opción, con comentarios que empiezan por "#"
+// y valores opcionalmente entre comillas. No admite valores anidados.
+func parseYAMLConfig(path string, data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if text[0] == ' ' || text[0] == '\t' || strings.HasPrefix(trimmed, "- ") {
+			return nil, fmt.Errorf("%s:%d: nested values are not supported", path, line)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && (value[0] == '"' || value[0] == '\''):
+			quote := value[0]
+			end := strings.IndexByte(value[1:], quote)
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated quoted value", path, line)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		if _, dup := settings[key]; dup {
+			return nil, fmt.Errorf("%s:%d: duplicate setting %q", path, line, key)
+		}
+		settings[key] = value
+	}
+	return settings, scanner.Err()
+}
@@ -0,0 +1,176 @@
+// Este archivo contiene pruebas unitarias para la carga de la configuración:
+// la prioridad entre flags, variables de entorno, archivo y valores por
+// defecto, los formatos de archivo admitidos y la validación.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadConfigPrecedence verifica que cada fuente prevalezca sobre las de
+// menor prioridad.
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, yamlPath, `# Configuración de prueba
+max-workers: 6
+queue-size: 50 # comentario al final
+retry-backoff: "2s"
+wal: ''
+log-format: json
+`)
+	jsonPath := filepath.Join(dir, "config.json")
+	writeFile(t, jsonPath, `{"max-workers": 5, "coalesce": false, "addr": "127.0.0.1:9000"}`)
+
+	testCases := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(t *testing.T, c *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, c *Config) {
+				if c.Addr != ":8081" || c.MaxWorkers != 4 || c.QueueSize != 20 || c.WALPath != "fibonacci.wal" || !c.Coalesce {
+					t.Errorf("config = %+v; want defaults", c)
+				}
+			},
+		},
+		{
+			name: "yaml file",
+			args: []string{"-config", yamlPath},
+			check: func(t *testing.T, c *Config) {
+				if c.MaxWorkers != 6 || c.QueueSize != 50 || c.RetryBackoff != 2*time.Second || c.WALPath != "" || c.LogFormat != "json" {
+					t.Errorf("config = %+v; want values from %s", c, yamlPath)
+				}
+			},
+		},
+		{
+			name: "json file from env",
+			env:  map[string]string{"FIBONACCI_CONFIG": jsonPath},
+			check: func(t *testing.T, c *Config) {
+				if c.MaxWorkers != 5 || c.Coalesce || c.Addr != "127.0.0.1:9000" {
+					t.Errorf("config = %+v; want values from %s", c, jsonPath)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			args: []string{"-config", yamlPath},
+			env:  map[string]string{"FIBONACCI_MAX_WORKERS": "8", "FIBONACCI_WAL": "jobs.wal"},
+			check: func(t *testing.T, c *Config) {
+				if c.MaxWorkers != 8 || c.WALPath != "jobs.wal" || c.QueueSize != 50 {
+					t.Errorf("config = %+v; want max-workers and wal from env, queue-size from file", c)
+				}
+			},
+		},
+		{
+			name: "flags over env",
+			args: []string{"-config", yamlPath, "-max-workers=10"},
+			env:  map[string]string{"FIBONACCI_MAX_WORKERS": "8"},
+			check: func(t *testing.T, c *Config) {
+				if c.MaxWorkers != 10 {
+					t.Errorf("MaxWorkers = %d; want 10 from flags", c.MaxWorkers)
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := LoadConfig("test", tc.args, mapEnv(tc.env))
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			tc.check(t, c)
+		})
+	}
+}
+
+// TestLoadConfigErrors verifica que los problemas de la configuración se
+// informen juntos y mencionen la opción afectada.
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.yaml")
+	writeFile(t, unknown, "max-wokers: 6\n")
+	nested := filepath.Join(dir, "nested.yaml")
+	writeFile(t, nested, "retry:\n  backoff: 2s\n")
+	toml := filepath.Join(dir, "config.toml")
+	writeFile(t, toml, "max-workers = 6\n")
+
+	testCases := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		wantErrs []string
+	}{
+		{name: "help", args: []string{"-h"}, wantErrs: []string{flag.ErrHelp.Error()}},
+		{name: "unknown setting", args: []string{"-config", unknown}, wantErrs: []string{`unknown setting "max-wokers"`}},
+		{name: "nested yaml", args: []string{"-config", nested}, wantErrs: []string{"nested values are not supported"}},
+		{name: "unsupported extension", args: []string{"-config", toml}, wantErrs: []string{"unsupported config file extension"}},
+		{name: "missing file", args: []string{"-config", filepath.Join(dir, "missing.json")}, wantErrs: []string{"reading config file"}},
+		{name: "invalid env", env: map[string]string{"FIBONACCI_QUEUE_SIZE": "many"}, wantErrs: []string{`FIBONACCI_QUEUE_SIZE: invalid value "many"`}},
+		{
+			name:     "validation",
+			args:     []string{"-max-workers=2", "-min-workers=3", "-max-attempts=0", "-queue-backlog=-1", "-job-retention=-1h", "-webhook-allow-networks=10.0.0.0", "-log-level=loud"},
+			wantErrs: []string{"min-workers must be between", "max-attempts must be at least 1", "queue-backlog must not be negative", "job-retention must not be negative", `network "10.0.0.0"`, `invalid log level "loud"`},
+		},
+		{
+			name:     "tls",
+			args:     []string{"-tls-key=server.key", "-tls-client-ca=ca.pem"},
+			wantErrs: []string{"tls-cert and tls-key must be set together", "tls-client-ca requires tls-cert", "tls-client-ca requires api-keys"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadConfig("test", tc.args, mapEnv(tc.env))
+			if err == nil {
+				t.Fatal("LoadConfig() error = nil; want error")
+			}
+			if tc.name == "help" && !errors.Is(err, flag.ErrHelp) {
+				t.Errorf("LoadConfig() error = %v; want flag.ErrHelp", err)
+			}
+			for _, want := range tc.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadConfig() error = %q; want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// TestConfigLogValueRedactsSecrets verifica que la configuración mostrada en
+// los logs no incluya los secretos.
+func TestConfigLogValueRedactsSecrets(t *testing.T) {
+	c, err := LoadConfig("test", []string{"-webhook-secret=s3cr3t"}, mapEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.LogValue().String()
+	if strings.Contains(got, "s3cr3t") || !strings.Contains(got, "webhook-secret=<redacted>") {
+		t.Errorf("LogValue() = %s; want webhook-secret redacted", got)
+	}
+}
+
+// mapEnv devuelve una función de lectura de variables de entorno que solo
+// conoce las de env.
+func mapEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// writeFile crea un archivo con el contenido indicado o detiene la prueba.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
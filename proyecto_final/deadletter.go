@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadLetter describe un trabajo que falló de forma definitiva tras agotar
+// sus reintentos.
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Number    int       `json:"number"`
+	Priority  Priority  `json:"priority"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+
+	job Job // Trabajo original, necesario para volver a enviarlo
+}
+
+// DeadLetterQueue guarda en memoria los trabajos fallidos definitivamente
+// para poder inspeccionarlos y volver a enviarlos. Es seguro para uso concurrente.
+//
+// Con Capacity, al llegar al máximo se descarta la entrada más antigua para
+// hacer sitio; con TTL, las entradas se descartan al cabo de ese tiempo. Las
+// descartadas se cuentan en Dropped. Capacity y TTL deben fijarse antes de
+// añadir entradas.
+type DeadLetterQueue struct {
+	Capacity int           // Número máximo de entradas, cero sin límite
+	TTL      time.Duration // Tiempo que se conserva cada entrada, cero para siempre
+	Dropped  Counter       // Entradas descartadas por Capacity o TTL
+
+	mu      sync.Mutex
+	entries map[string]DeadLetter
+}
+
+// NewDeadLetterQueue crea una cola de mensajes muertos vacía y sin límites.
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{
+		entries: make(map[string]DeadLetter),
+	}
+}
+
+// Add registra un trabajo fallido junto con el último error obtenido.
+func (q *DeadLetterQueue) Add(job Job, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.insert(DeadLetter{
+		ID:        job.ID,
+		Name:      job.Name,
+		Number:    job.Number,
+		Priority:  job.Priority,
+		Attempts:  job.Attempt,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+		job:       job,
+	})
+}
+
+// restore vuelve a insertar un trabajo retirado con Remove.
+func (q *DeadLetterQueue) restore(dl DeadLetter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.insert(dl)
+}
+
+// insert guarda la entrada tras descartar las caducadas y, si la cola está
+// llena, la más antigua. Debe llamarse con mu tomado.
+func (q *DeadLetterQueue) insert(dl DeadLetter) {
+	q.expire(time.Now())
+	if _, ok := q.entries[dl.ID]; !ok && q.Capacity > 0 && len(q.entries) >= q.Capacity {
+		var oldest DeadLetter
+		for _, e := range q.entries {
+			if oldest.ID == "" || e.FailedAt.Before(oldest.FailedAt) {
+				oldest = e
+			}
+		}
+		delete(q.entries, oldest.ID)
+		q.Dropped.Inc()
+	}
+	q.entries[dl.ID] = dl
+}
+
+// expire descarta las entradas que superaron TTL. Debe llamarse con mu tomado.
+func (q *DeadLetterQueue) expire(now time.Time) {
+	if q.TTL <= 0 {
+		return
+	}
+	for id, dl := range q.entries {
+		if now.Sub(dl.FailedAt) >= q.TTL {
+			delete(q.entries, id)
+			q.Dropped.Inc()
+		}
+	}
+}
+
+// Len devuelve el número de trabajos en la cola.
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	return len(q.entries)
+}
+
+// List devuelve los trabajos fallidos ordenados del más antiguo al más reciente.
+func (q *DeadLetterQueue) List() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	list := make([]DeadLetter, 0, len(q.entries))
+	for _, dl := range q.entries {
+		list = append(list, dl)
+	}
+	slices.SortFunc(list, func(a, b DeadLetter) int { return a.FailedAt.Compare(b.FailedAt) })
+	return list
+}
+
+// Get devuelve el trabajo fallido con el ID indicado.
+func (q *DeadLetterQueue) Get(id string) (DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	dl, ok := q.entries[id]
+	return dl, ok
+}
+
+// Remove elimina un trabajo de la cola y lo devuelve.
+func (q *DeadLetterQueue) Remove(id string) (DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(time.Now())
+	dl, ok := q.entries[id]
+	delete(q.entries, id)
+	return dl, ok
+}
+
+// DeadLetterHandler maneja las solicitudes sobre la cola de mensajes muertos:
+//   - GET /deadletters: lista los trabajos fallidos definitivamente.
+//   - GET /deadletters/{id}: devuelve un trabajo fallido.
+//   - DELETE /deadletters/{id}: descarta un trabajo fallido.
+//   - POST /deadletters/{id}/redrive: vuelve a enviar el trabajo como uno
+//     nuevo, con la misma política de reintentos y sin fecha límite, y
+//     responde 201 con el registro del nuevo trabajo.
+func DeadLetterHandler(w http.ResponseWriter, r *http.Request, dispatcher *Dispatcher, store *JobStore) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/deadletters"), "/")
+	id, action, _ := strings.Cut(path, "/")
+	dlq := dispatcher.DeadLetters
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, dlq.List())
+
+	case id == "":
+		writeMethodNotAllowed(w, http.MethodGet)
+
+	case action == "redrive" && r.Method == http.MethodPost:
+		dl, ok := dlq.Remove(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Dead letter not found", nil)
+			return
+		}
+		job := NewJob(dl.job.Name, dl.job.Number, dl.job.Delay, time.Time{}, dl.job.Priority)
+		job.Retry = dl.job.Retry
+		job.CallbackURL = dl.job.CallbackURL
+		job.Owner = dl.job.Owner
+		if err := dispatcher.Enqueue(job, 0); err != nil {
+			dlq.restore(dl)
+			writeEnqueueError(w, dispatcher, job, err)
+			return
+		}
+		dispatcher.Logger.Info("dead letter redriven", append(jobAttrs(job), "dead_letter_id", dl.ID)...)
+		w.Header().Set("Location", "/fibonacci/"+job.ID)
+		rec, _ := store.Get(job.ID)
+		writeJSON(w, http.StatusCreated, rec)
+
+	case action == "redrive":
+		writeMethodNotAllowed(w, http.MethodPost)
+
+	case action != "":
+		writeError(w, http.StatusNotFound, "not_found", "Not found", nil)
+
+	case r.Method == http.MethodGet:
+		dl, ok := dlq.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Dead letter not found", nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, dl)
+
+	case r.Method == http.MethodDelete:
+		if _, ok := dlq.Remove(id); !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Dead letter not found", nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
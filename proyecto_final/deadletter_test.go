@@ -0,0 +1,120 @@
+// Este archivo contiene pruebas unitarias para la cola de mensajes muertos y
+// su API. El dispatcher no se arranca: los trabajos reenviados quedan en la
+// cola.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestDeadLetterHandlerRedrive verifica que el reenvío cree un trabajo nuevo
+// con los datos del original y lo retire de la cola, y que si la cola de
+// trabajos está llena la entrada se conserve.
+func TestDeadLetterHandlerRedrive(t *testing.T) {
+	testCases := []struct {
+		name       string
+		id         string // Vacío para reenviar el trabajo fallido
+		prefill    bool   // Llena la cola de trabajos antes del reenvío
+		wantStatus int
+		wantKept   bool // La entrada sigue en la cola de mensajes muertos
+	}{
+		{name: "redrive", wantStatus: http.StatusCreated},
+		{name: "queue full", prefill: true, wantStatus: http.StatusServiceUnavailable, wantKept: true},
+		{name: "missing", id: "missing", wantStatus: http.StatusNotFound, wantKept: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			d := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+			failed := NewJob("failed", 10, time.Second, time.Now().Add(time.Minute), PriorityHigh)
+			failed.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute}
+			failed.Owner = "alice"
+			failed.Attempt = 3
+			d.DeadLetters.Add(failed, errors.New("boom"))
+			if tc.prefill {
+				if err := d.Enqueue(NewJob("prefill", 11, 0, time.Time{}, PriorityNormal), 0); err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+			}
+			id := tc.id
+			if id == "" {
+				id = failed.ID
+			}
+
+			rec := httptest.NewRecorder()
+			DeadLetterHandler(rec, httptest.NewRequest(http.MethodPost, "/deadletters/"+id+"/redrive", nil), d, store)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if _, ok := d.DeadLetters.Get(failed.ID); ok != tc.wantKept {
+				t.Errorf("dead letter kept = %v; want %v", ok, tc.wantKept)
+			}
+			if tc.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var body struct{ ID string }
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got, _ := store.Get(body.ID); body.ID == failed.ID || got.Status != StatusQueued || got.Deadline != nil {
+				t.Errorf("record = %+v; want a new queued job without deadline", got)
+			}
+			job, ok := d.JobQueue.Pop(nil)
+			if !ok || job.ID != body.ID || job.Attempt != 1 || job.Number != 10 || job.Priority != PriorityHigh ||
+				job.Retry != failed.Retry || job.Owner != "alice" {
+				t.Errorf("queued job = %+v; want a copy of the failed job on attempt 1", job)
+			}
+		})
+	}
+}
+
+// TestDeadLetterQueueLimits verifica que la cola descarte la entrada más
+// antigua al llegar a Capacity y las que superan TTL, contándolas en Dropped.
+func TestDeadLetterQueueLimits(t *testing.T) {
+	testCases := []struct {
+		name        string
+		capacity    int
+		ttl         time.Duration
+		wait        time.Duration // Espera antes de consultar la cola
+		wantKept    []int         // Índices de los trabajos que siguen en la cola
+		wantDropped uint64
+	}{
+		{name: "unbounded", wantKept: []int{0, 1, 2}},
+		{name: "capacity", capacity: 2, wantKept: []int{1, 2}, wantDropped: 1},
+		{name: "ttl", ttl: 20 * time.Millisecond, wait: 40 * time.Millisecond, wantDropped: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewDeadLetterQueue()
+			q.Capacity, q.TTL = tc.capacity, tc.ttl
+			jobs := make([]Job, 3)
+			for i := range jobs {
+				jobs[i] = NewJob("failed", i, 0, time.Time{}, PriorityNormal)
+				q.Add(jobs[i], errors.New("boom"))
+				time.Sleep(time.Millisecond) // Ordena las entradas por FailedAt.
+			}
+			time.Sleep(tc.wait)
+
+			list := q.List()
+			if len(list) != len(tc.wantKept) {
+				t.Fatalf("List() has %d entries; want %d", len(list), len(tc.wantKept))
+			}
+			for k, i := range tc.wantKept {
+				if list[k].ID != jobs[i].ID {
+					t.Errorf("List()[%d] = %s; want job %d", k, list[k].ID, i)
+				}
+			}
+			if got := q.Dropped.Value(); got != tc.wantDropped {
+				t.Errorf("Dropped = %d; want %d", got, tc.wantDropped)
+			}
+		})
+	}
+}
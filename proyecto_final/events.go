@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event es un cambio de estado de un trabajo publicado a los suscriptores.
+// Su tipo es "job." seguido del nuevo estado (ej: "job.queued",
+// "job.running", "job.done").
+type Event struct {
+	ID   uint64    // Número de secuencia, creciente en todo el servidor
+	Type string    // Tipo del evento
+	Job  JobRecord // Registro del trabajo tras el cambio
+}
+
+// EventBroker reparte los cambios de estado de los trabajos entre los
+// suscriptores de los streams de eventos. Publicar nunca bloquea: un
+// suscriptor que no consume sus eventos a tiempo se desconecta.
+// Es seguro para uso concurrente.
+type EventBroker struct {
+	Dropped Counter      // Suscriptores desconectados por no consumir a tiempo
+	Logger  *slog.Logger // Logger donde se registran los eventos que no se pudieron serializar
+
+	mu     sync.Mutex
+	seq    uint64
+	subs   map[*subscriber]struct{}
+	buffer int
+	closed bool
+}
+
+// subscriber es un stream de eventos abierto.
+type subscriber struct {
+	jobID  string     // Trabajo al que se limita, o vacío para todos
+	events chan Event // Se cierra al desconectar al suscriptor
+}
+
+// NewEventBroker crea un broker sin suscriptores.
+//
+// Parámetros:
+//   - buffer: Eventos pendientes que admite cada suscriptor antes de desconectarse
+//
+// Retorna:
+//   - *EventBroker: Nuevo broker
+func NewEventBroker(buffer int) *EventBroker {
+	return &EventBroker{
+		Logger: slog.Default(),
+		subs:   make(map[*subscriber]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Observe publica la transición de estado de un trabajo. Está pensada para
+// registrarse con JobStore.Observe.
+func (b *EventBroker) Observe(rec JobRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	ev := Event{ID: b.seq, Type: "job." + string(rec.Status), Job: rec}
+	for sub := range b.subs {
+		if sub.jobID != "" && sub.jobID != rec.ID {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default: // El suscriptor va retrasado: se desconecta en lugar de bloquear.
+			delete(b.subs, sub)
+			close(sub.events)
+			b.Dropped.Inc()
+		}
+	}
+}
+
+// Subscribe abre un stream con los eventos del trabajo jobID, o de todos los
+// trabajos si jobID está vacío. El canal se cierra si el suscriptor se
+// desconecta por ir retrasado o al cerrar el broker; la función devuelta
+// cancela la suscripción y debe llamarse siempre.
+func (b *EventBroker) Subscribe(jobID string) (<-chan Event, func()) {
+	sub := &subscriber{jobID: jobID, events: make(chan Event, b.buffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	b.subs[sub] = struct{}{}
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribers devuelve el número de streams abiertos.
+func (b *EventBroker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close cierra todos los streams abiertos y rechaza los nuevos. Está pensada
+// para registrarse con http.Server.RegisterOnShutdown, ya que Shutdown
+// espera a que terminen las conexiones activas.
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// eventHeartbeat es cada cuánto se envía un comentario por un stream sin
+// eventos, para que los proxies no cierren la conexión.
+const eventHeartbeat = 15 * time.Second
+
+// EventsHandler maneja los streams de Server-Sent Events:
+//   - GET /events: emite un evento por cada cambio de estado de cualquier
+//     trabajo.
+//   - GET /fibonacci/{id}/events: emite primero el estado actual del trabajo
+//     y después sus cambios, y termina cuando alcanza un estado final.
+//
+// Cada mensaje lleva el tipo del evento en "event" y el registro del trabajo
+// en JSON en "data". Si el cliente no consume los eventos a tiempo, el
+// stream se cierra; EventSource vuelve a conectar automáticamente. Con
+// autenticación, cada cliente solo recibe los eventos de sus trabajos salvo
+// que tenga el permiso admin.
+func EventsHandler(w http.ResponseWriter, r *http.Request, broker *EventBroker, store *JobStore) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "internal_error", "Streaming not supported", nil)
+		return
+	}
+
+	jobID := ""
+	if r.URL.Path != "/events" {
+		jobID = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/fibonacci/"), "/events")
+	}
+	// La suscripción se abre antes de leer el estado actual para no perder
+	// ningún cambio intermedio.
+	events, unsubscribe := broker.Subscribe(jobID)
+	defer unsubscribe()
+
+	var current *JobRecord
+	if jobID != "" {
+		rec, ok := store.Get(jobID)
+		if !ok || !visibleTo(r, rec.Owner) {
+			writeError(w, http.StatusNotFound, "not_found", "Job not found", nil)
+			return
+		}
+		current = &rec
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if current != nil {
+		writeEvent(w, Event{Type: "job." + string(current.Status), Job: *current}, broker.Logger)
+		if current.Status.Terminal() {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return // Desconectado por ir retrasado o por el cierre del servidor.
+			}
+			if !visibleTo(r, ev.Job.Owner) {
+				continue // Los clientes sin permiso admin solo ven sus trabajos.
+			}
+			writeEvent(w, ev, broker.Logger)
+			flusher.Flush()
+			if jobID != "" && ev.Job.Status.Terminal() {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent escribe ev en el formato de Server-Sent Events. El estado
+// actual que se envía al abrir un stream no tiene número de secuencia.
+func writeEvent(w http.ResponseWriter, ev Event, logger *slog.Logger) {
+	data, err := json.Marshal(ev.Job)
+	if err != nil {
+		logger.Error("could not encode event", logError, err)
+		return
+	}
+	if ev.ID != 0 {
+		fmt.Fprintf(w, "id: %d\n", ev.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+}
@@ -0,0 +1,128 @@
+// Este archivo contiene pruebas unitarias para los streams de eventos. Las
+// transiciones se simulan directamente sobre el JobStore, que notifica al
+// broker como lo haría un worker.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEventBrokerDropsSlowSubscriber verifica que un suscriptor con el
+// buffer lleno se desconecte sin bloquear y que cada stream reciba solo los
+// eventos de su trabajo.
+func TestEventBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewEventBroker(1)
+	all, unsubscribeAll := broker.Subscribe("")
+	defer unsubscribeAll()
+	one, unsubscribeOne := broker.Subscribe("b")
+	defer unsubscribeOne()
+
+	broker.Observe(JobRecord{ID: "a", Status: StatusQueued})
+	broker.Observe(JobRecord{ID: "a", Status: StatusRunning}) // El primer suscriptor ya no tiene hueco.
+	broker.Observe(JobRecord{ID: "b", Status: StatusQueued})
+
+	if ev := <-all; ev.Type != "job.queued" || ev.Job.ID != "a" {
+		t.Errorf("first event = %s %s; want job.queued a", ev.Type, ev.Job.ID)
+	}
+	if _, ok := <-all; ok {
+		t.Error("slow subscriber still open; want closed")
+	}
+	if ev := <-one; ev.Job.ID != "b" || ev.ID != 3 {
+		t.Errorf("job stream event = %d %s; want event 3 for b", ev.ID, ev.Job.ID)
+	}
+	if got := broker.Dropped.Value(); got != 1 {
+		t.Errorf("Dropped = %d; want 1", got)
+	}
+	if got := broker.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d; want 1", got)
+	}
+}
+
+// TestEventBrokerIgnoresRejectedJobs verifica que los trabajos rechazados
+// por tener la cola llena, sueltos o en lote, no emitan ningún evento.
+func TestEventBrokerIgnoresRejectedJobs(t *testing.T) {
+	store := NewJobStore()
+	broker := NewEventBroker(10)
+	store.Observe(broker.Observe)
+	dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+	events, unsubscribe := broker.Subscribe("")
+	defer unsubscribe()
+
+	accepted := NewJob("accepted", 10, 0, time.Time{}, PriorityNormal)
+	if err := dispatcher.Enqueue(accepted, 0); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := dispatcher.Enqueue(NewJob("rejected", 11, 0, time.Time{}, PriorityNormal), 0); err == nil {
+		t.Fatal("Enqueue() on a full queue error = nil; want error")
+	}
+	batch := []Job{NewJob("batch", 12, 0, time.Time{}, PriorityNormal), NewJob("batch", 13, 0, time.Time{}, PriorityNormal)}
+	if err := dispatcher.EnqueueAll(batch, 0); err == nil {
+		t.Fatal("EnqueueAll() on a full queue error = nil; want error")
+	}
+
+	if ev := <-events; ev.Type != "job.queued" || ev.Job.ID != accepted.ID {
+		t.Errorf("event = %s %s; want job.queued %s", ev.Type, ev.Job.ID, accepted.ID)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %s for job %s", ev.Type, ev.Job.Name)
+	default:
+	}
+}
+
+// TestEventsHandlerJobStream verifica que el stream de un trabajo emita su
+// estado actual y sus cambios, y que termine al alcanzar un estado final.
+func TestEventsHandlerJobStream(t *testing.T) {
+	store := NewJobStore()
+	broker := NewEventBroker(10)
+	store.Observe(broker.Observe)
+	job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+	store.Add(job)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		EventsHandler(rec, httptest.NewRequest(http.MethodGet, "/fibonacci/"+job.ID+"/events", nil), broker, store)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for broker.Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond) // Espera a que el handler se suscriba.
+	}
+	store.MarkRunning(job.ID, 2)
+	store.MarkDone(job.ID, "55")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end after the job finished")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q; want text/event-stream", ct)
+	}
+	body := rec.Body.String()
+	var types []string
+	for _, line := range strings.Split(body, "\n") {
+		if after, ok := strings.CutPrefix(line, "event: "); ok {
+			types = append(types, after)
+		}
+	}
+	if got, want := strings.Join(types, ","), "job.queued,job.running,job.done"; got != want {
+		t.Errorf("event types = %s; want %s\n%s", got, want, body)
+	}
+	if !strings.Contains(body, `"worker":2`) {
+		t.Errorf("stream missing assigned worker:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	EventsHandler(rec, httptest.NewRequest(http.MethodGet, "/fibonacci/missing/events", nil), broker, store)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing job status = %d; want %d", rec.Code, http.StatusNotFound)
+	}
+}
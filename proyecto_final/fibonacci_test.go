@@ -0,0 +1,74 @@
+// Este archivo contiene pruebas unitarias para el cálculo de Fibonacci con
+// precisión arbitraria.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// TestFibonacci verifica valores conocidos, incluidos los que desbordan int64.
+func TestFibonacci(t *testing.T) {
+	testCases := []struct {
+		n    int
+		want string
+	}{
+		{n: 0, want: "0"},
+		{n: 1, want: "1"},
+		{n: 2, want: "1"},
+		{n: 10, want: "55"},
+		{n: 50, want: "12586269025"},
+		{n: 92, want: "7540113804746346429"},
+		{n: 93, want: "12200160415121876738"},
+		{n: 100, want: "354224848179261915075"},
+	}
+	for _, tc := range testCases {
+		got, err := Fibonacci(context.Background(), tc.n)
+		if err != nil {
+			t.Fatalf("Fibonacci(%d) error = %v", tc.n, err)
+		}
+		if got.String() != tc.want {
+			t.Errorf("Fibonacci(%d) = %s; want %s", tc.n, got, tc.want)
+		}
+	}
+}
+
+// TestFibonacciMatchesIteration compara el fast doubling con la suma
+// iterativa para todos los valores hasta 1000.
+func TestFibonacciMatchesIteration(t *testing.T) {
+	a, b := big.NewInt(0), big.NewInt(1)
+	for n := 0; n <= 1000; n++ {
+		got, err := Fibonacci(context.Background(), n)
+		if err != nil {
+			t.Fatalf("Fibonacci(%d) error = %v", n, err)
+		}
+		if got.Cmp(a) != 0 {
+			t.Fatalf("Fibonacci(%d) = %s; want %s", n, got, a)
+		}
+		a.Add(a, b)
+		a, b = b, a
+	}
+}
+
+// TestFibonacciErrors verifica los números negativos y la cancelación.
+func TestFibonacciErrors(t *testing.T) {
+	if _, err := Fibonacci(context.Background(), -1); !errors.Is(err, ErrNegativeNumber) {
+		t.Errorf("Fibonacci(-1) error = %v; want %v", err, ErrNegativeNumber)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(ErrJobCancelled)
+	if _, err := Fibonacci(ctx, 500_000); !errors.Is(err, ErrJobCancelled) {
+		t.Errorf("Fibonacci with cancelled context error = %v; want %v", err, ErrJobCancelled)
+	}
+}
+
+// BenchmarkFibonacci mide el cálculo de un valor grande.
+func BenchmarkFibonacci(b *testing.B) {
+	for range b.N {
+		Fibonacci(context.Background(), 500_000)
+	}
+}
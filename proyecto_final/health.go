@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthStatus es el resultado de una comprobación de salud.
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthFail HealthStatus = "fail"
+)
+
+// CheckResult es el resultado de una de las comprobaciones de /readyz.
+type CheckResult struct {
+	Status  HealthStatus `json:"status"`
+	Message string       `json:"message"`
+}
+
+// HealthReport es la respuesta de /healthz y /readyz. Status es "fail" si
+// falla alguna de las comprobaciones.
+type HealthReport struct {
+	Status HealthStatus           `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// ShuttingDown indica si el dispatcher ha dejado de aceptar trabajos porque
+// se está deteniendo.
+func (d *Dispatcher) ShuttingDown() bool {
+	return d.JobQueue.isClosed()
+}
+
+// StuckWorkers devuelve el número de workers que llevan más de after con su
+// trabajo actual.
+func (d *Dispatcher) StuckWorkers(after time.Duration) int {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	stuck := 0
+	for _, worker := range d.workers {
+		if worker.BusyFor() > after {
+			stuck++
+		}
+	}
+	return stuck
+}
+
+// CheckReadiness comprueba si el servidor puede aceptar trabajos:
+//   - dispatcher: falla si el dispatcher se está deteniendo.
+//   - queue: falla si el JobQueue está lleno.
+//   - workers: falla si no hay workers o si todos llevan más de stuckAfter
+//     con el mismo trabajo.
+func CheckReadiness(d *Dispatcher, stuckAfter time.Duration) HealthReport {
+	report := HealthReport{Status: HealthOK, Checks: make(map[string]CheckResult)}
+	check := func(name string, ok bool, format string, args ...any) {
+		result := CheckResult{Status: HealthOK, Message: fmt.Sprintf(format, args...)}
+		if !ok {
+			result.Status = HealthFail
+			report.Status = HealthFail
+		}
+		report.Checks[name] = result
+	}
+
+	if d.ShuttingDown() {
+		check("dispatcher", false, "shutting down")
+	} else {
+		check("dispatcher", true, "accepting jobs")
+	}
+
+	queued, capacity := d.JobQueue.Len(), d.JobQueue.Cap()
+	check("queue", queued < capacity, "%d of %d slots in use", queued, capacity)
+
+	workers, stuck := d.WorkerCount(), d.StuckWorkers(stuckAfter)
+	switch {
+	case workers == 0:
+		check("workers", false, "no workers running")
+	case stuck == workers:
+		check("workers", false, "all %d workers busy with the same job for more than %v", workers, stuckAfter)
+	default:
+		check("workers", true, "%d of %d workers busy with the same job for more than %v", stuck, workers, stuckAfter)
+	}
+	return report
+}
+
+// HealthHandler maneja GET /healthz: responde 200 mientras el proceso esté
+// vivo y atendiendo peticiones, aunque no acepte trabajos.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, HealthReport{Status: HealthOK})
+}
+
+// ReadinessHandler maneja GET /readyz: responde 200 si el servidor puede
+// aceptar trabajos y 503 si no, con el resultado de cada comprobación de
+// CheckReadiness.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request, dispatcher *Dispatcher, stuckAfter time.Duration) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+	report := CheckReadiness(dispatcher, stuckAfter)
+	status := http.StatusOK
+	if report.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
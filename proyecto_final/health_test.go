@@ -0,0 +1,126 @@
+// Este archivo contiene pruebas unitarias para los endpoints de salud y
+// disponibilidad que consulta el orquestador.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReadinessHandler verifica el resultado de cada comprobación de
+// /readyz según el estado del dispatcher.
+func TestReadinessHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setup      func(t *testing.T, d *Dispatcher)
+		wantStatus int
+		wantChecks map[string]HealthStatus
+	}{
+		{
+			name:       "ready",
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]HealthStatus{"dispatcher": HealthOK, "queue": HealthOK, "workers": HealthOK},
+		},
+		{
+			name: "queue saturated",
+			setup: func(t *testing.T, d *Dispatcher) {
+				// Sin Dispatch, los trabajos se quedan en la cola.
+				d.addWorkers(1)
+				for range d.JobQueue.Cap() {
+					if err := d.Enqueue(NewJob("job", 10, 0, time.Time{}, PriorityNormal), 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]HealthStatus{"dispatcher": HealthOK, "queue": HealthFail, "workers": HealthOK},
+		},
+		{
+			name: "shutting down",
+			setup: func(t *testing.T, d *Dispatcher) {
+				d.addWorkers(1)
+				d.JobQueue.Close()
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]HealthStatus{"dispatcher": HealthFail, "queue": HealthOK, "workers": HealthOK},
+		},
+		{
+			name: "workers stuck",
+			setup: func(t *testing.T, d *Dispatcher) {
+				d.Run()
+				t.Cleanup(func() {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+					defer cancel()
+					d.Stop(ctx)
+				})
+				for range d.MaxWorkers {
+					job := NewJob("slow", 10, time.Minute, time.Time{}, PriorityNormal)
+					t.Cleanup(func() { d.Store.Cancel(job.ID) })
+					if err := d.Enqueue(job, 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+				deadline := time.Now().Add(time.Second)
+				for d.StuckWorkers(10*time.Millisecond) < d.MaxWorkers {
+					if time.Now().After(deadline) {
+						t.Fatal("workers did not pick up the slow jobs")
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]HealthStatus{"dispatcher": HealthOK, "queue": HealthOK, "workers": HealthFail},
+		},
+		{
+			name:       "no workers",
+			setup:      func(t *testing.T, d *Dispatcher) {},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]HealthStatus{"dispatcher": HealthOK, "queue": HealthOK, "workers": HealthFail},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDispatcher(NewJobQueue(3, 0, time.Second), 2, NewJobStore(), nil)
+			if tc.setup != nil {
+				tc.setup(t, d)
+			} else {
+				d.addWorkers(2)
+			}
+
+			rec := httptest.NewRecorder()
+			ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil), d, 10*time.Millisecond)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			var report HealthReport
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+			for name, want := range tc.wantChecks {
+				if got := report.Checks[name]; got.Status != want || got.Message == "" {
+					t.Errorf("check %s = %+v; want status %s with a message", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestHealthHandler verifica que /healthz responda 200 y rechace otros métodos.
+func TestHealthHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "{\"status\":\"ok\"}\n" {
+		t.Errorf("GET /healthz = %d %s; want 200 {\"status\":\"ok\"}", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	HealthHandler(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /healthz = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
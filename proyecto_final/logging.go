@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// NewLogger crea el logger del servidor.
+//
+// Parámetros:
+//   - w: Destino de los logs
+//   - format: "text" para líneas clave=valor o "json" para un objeto JSON por línea
+//   - level: Nivel mínimo de los mensajes: "debug", "info", "warn" o "error"
+//
+// Retorna:
+//   - *slog.Logger: Logger configurado
+//   - error: Si el formato o el nivel no son válidos
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+// Campos comunes de los logs, para que se puedan filtrar igual en todos los
+// mensajes.
+const (
+	logJobID    = "job_id"
+	logJobName  = "job_name"
+	logNumber   = "number"
+	logWorkerID = "worker_id"
+	logAttempt  = "attempt"
+	logDuration = "duration"
+	logError    = "error"
+	logOwner    = "owner"
+)
+
+// jobAttrs devuelve los campos que identifican a un trabajo en los logs,
+// incluido su dueño si lo tiene.
+func jobAttrs(job Job) []any {
+	attrs := []any{logJobID, job.ID, logJobName, job.Name, logNumber, job.Number}
+	if job.Owner != "" {
+		attrs = append(attrs, logOwner, job.Owner)
+	}
+	return attrs
+}
+
+// recordAttrs devuelve los campos que identifican al trabajo de un registro
+// en los logs, incluido su dueño si lo tiene.
+func recordAttrs(rec JobRecord) []any {
+	attrs := []any{logJobID, rec.ID, logJobName, rec.Name, logNumber, rec.Number}
+	if rec.Owner != "" {
+		attrs = append(attrs, logOwner, rec.Owner)
+	}
+	return attrs
+}
+
+// loggerOrDefault devuelve logger, o el logger por defecto de slog si es nil.
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
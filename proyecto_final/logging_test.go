@@ -0,0 +1,78 @@
+// Este archivo contiene pruebas unitarias para la configuración del logger y
+// para los campos que acompañan a los logs de los workers.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewLogger verifica los formatos y niveles admitidos.
+func TestNewLogger(t *testing.T) {
+	testCases := []struct {
+		name      string
+		format    string
+		level     string
+		wantErr   bool
+		wantDebug bool // Si los mensajes de depuración llegan a la salida
+	}{
+		{name: "text info", format: "text", level: "info"},
+		{name: "json debug", format: "json", level: "debug", wantDebug: true},
+		{name: "upper case level", format: "text", level: "WARN"},
+		{name: "unknown format", format: "xml", level: "info", wantErr: true},
+		{name: "unknown level", format: "json", level: "verbose", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := NewLogger(&buf, tc.format, tc.level)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewLogger() error = %v; want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			logger.Debug("probe")
+			if got := buf.Len() > 0; got != tc.wantDebug {
+				t.Errorf("debug logged = %v; want %v", got, tc.wantDebug)
+			}
+		})
+	}
+}
+
+// TestWorkerLogsJobFields verifica que los logs de un trabajo procesado
+// incluyan los campos que lo identifican a él y al worker.
+func TestWorkerLogsJobFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewJobStore()
+	worker := NewWorker(3, nil, store, nil, logger)
+	job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+	store.Add(job)
+	worker.process(job)
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		messages = append(messages, entry["msg"].(string))
+		if entry[logJobID] != job.ID || entry[logJobName] != "test" || entry[logNumber] != 10.0 || entry[logWorkerID] != 3.0 {
+			t.Errorf("entry = %v; want job %s, name test, number 10 and worker 3", entry, job.ID)
+		}
+	}
+	if strings.Join(messages, ",") != "job started,job done" {
+		t.Errorf("messages = %v; want [job started job done]", messages)
+	}
+	if !strings.Contains(buf.String(), `"duration"`) {
+		t.Errorf("logs = %s; want a duration field", buf.String())
+	}
+}
@@ -6,30 +6,121 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"math/big"
+	"math/bits"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 // Job representa un trabajo que debe ser procesado por un worker.
-// Contiene el número para calcular su Fibonacci, un nombre identificativo
-// y un delay para simular procesamiento.
+// Contiene el número para calcular su Fibonacci, un nombre identificativo,
+// un delay para simular procesamiento y la prioridad con la que se despacha.
 type Job struct {
-	Number int           // Número para el cual calcular el Fibonacci
-	Name   string        // Nombre identificativo del trabajo
-	Delay  time.Duration // Tiempo de espera para simular procesamiento
+	ID       string        // Identificador único generado por el servidor
+	Number   int           // Número para el cual calcular el Fibonacci
+	Name     string        // Nombre identificativo del trabajo
+	Delay    time.Duration // Tiempo de espera para simular procesamiento
+	Deadline time.Time     // Fecha límite para terminar el trabajo, cero si no tiene
+	Priority Priority      // Prioridad del trabajo en el JobQueue
+	Retry    RetryPolicy   // Política de reintentos si el trabajo falla
+	Attempt  int           // Número del intento actual, empezando en 1
+
+	CallbackURL string // URL a la que se notifica el resultado, o vacía
+	Owner       string // Identidad del cliente que creó el trabajo, o vacía sin autenticación
+
+	ctx    context.Context         // Se cancela cuando el trabajo debe abortarse
+	cancel context.CancelCauseFunc // Cancela ctx indicando el motivo
+}
+
+// NewJob crea un trabajo con un ID nuevo y un contexto cancelable propio.
+// Si deadline no es cero, el contexto expira en esa fecha con ErrJobTimedOut
+// como causa.
+//
+// Parámetros:
+//   - name: Nombre identificativo del trabajo
+//   - number: Número para el cual calcular el Fibonacci
+//   - delay: Tiempo de espera para simular procesamiento
+//   - deadline: Fecha límite del trabajo, o time.Time{} si no tiene
+//   - priority: Prioridad con la que se despachará el trabajo
+//
+// Retorna:
+//   - Job: Nuevo trabajo listo para registrarse y encolarse
+func NewJob(name string, number int, delay time.Duration, deadline time.Time, priority Priority) Job {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	if !deadline.IsZero() {
+		var stop context.CancelFunc
+		ctx, stop = context.WithDeadlineCause(ctx, deadline, ErrJobTimedOut)
+		parentCancel := cancel
+		cancel = func(cause error) {
+			parentCancel(cause)
+			stop()
+		}
+	}
+	return Job{
+		ID:       NewJobID(),
+		Number:   number,
+		Name:     name,
+		Delay:    delay,
+		Deadline: deadline,
+		Priority: priority,
+		Attempt:  1,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Context devuelve el contexto del trabajo, que se cancela si el cliente
+// cancela el trabajo o si se alcanza su fecha límite.
+func (j Job) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// release cancela el contexto de un trabajo que no llegó a aceptarse, con
+// err como causa, para que no quede pendiente el temporizador de su fecha
+// límite.
+func (j Job) release(err error) {
+	if j.cancel != nil {
+		j.cancel(err)
+	}
 }
 
 // Worker representa un trabajador que procesa jobs de forma concurrente.
 // Cada worker tiene su propio canal de trabajos y se comunica con el dispatcher
 // a través del WorkerPool para recibir trabajos y reportar su disponibilidad.
 type Worker struct {
-	Id         int           // Identificador único del worker
-	JobQueue   chan Job      // Canal para recibir trabajos específicos de este worker
-	WorkerPool chan chan Job // Canal compartido para reportar disponibilidad al pool
-	QuitChan   chan bool     // Canal para recibir señales de parada
+	Id         int                // Identificador único del worker
+	JobQueue   chan Job           // Canal para recibir trabajos específicos de este worker
+	WorkerPool chan chan Job      // Canal compartido para reportar disponibilidad al pool
+	QuitChan   chan bool          // Se cierra para indicar al worker que se detenga
+	Store      *JobStore          // Registro donde se reporta el estado de cada trabajo
+	OnFailure  func(Job, error)   // Decide qué hacer con un intento fallido, nil para marcarlo como fallido
+	Retire     func(*Worker) bool // Indica tras cada trabajo si el worker debe retirarse, o nil
+	Cache      *ResultCache       // Caché de resultados compartida, nil para calcular siempre
+	Logger     *slog.Logger       // Logger con el campo worker_id del worker
+
+	stopped   chan struct{} // Se cierra cuando la goroutine del worker termina
+	stopOnce  sync.Once     // Garantiza que QuitChan se cierre una sola vez
+	processed atomic.Uint64 // Intentos procesados por el worker
+	mu        sync.Mutex    // Protege current y busySince
+	current   *Job          // Trabajo en ejecución, nil si el worker está libre
+	busySince time.Time     // Momento en que empezó el trabajo en ejecución
 }
 
 // NewWorker crea una nueva instancia de Worker con el ID especificado.
@@ -38,15 +129,22 @@ type Worker struct {
 // Parámetros:
 //   - id: Identificador único para el worker
 //   - workerPool: Canal compartido donde el worker reportará su disponibilidad
+//   - store: Registro donde el worker actualizará el estado de los trabajos
+//   - onFailure: Función a la que se entregan los intentos fallidos, o nil
+//   - logger: Logger donde el worker registra cada trabajo, o nil para el de slog por defecto
 //
 // Retorna:
 //   - *Worker: Nueva instancia de worker configurada
-func NewWorker(id int, workerPool chan chan Job) *Worker {
+func NewWorker(id int, workerPool chan chan Job, store *JobStore, onFailure func(Job, error), logger *slog.Logger) *Worker {
 	return &Worker{
 		Id:         id,
 		WorkerPool: workerPool,
+		Store:      store,
+		OnFailure:  onFailure,
+		Logger:     loggerOrDefault(logger).With(logWorkerID, id),
 		JobQueue:   make(chan Job),
 		QuitChan:   make(chan bool),
+		stopped:    make(chan struct{}),
 	}
 }
 
@@ -55,156 +153,954 @@ func NewWorker(id int, workerPool chan chan Job) *Worker {
 // y espera a recibir trabajos o señales de parada.
 //
 // El método no bloquea y el worker continuará ejecutándose hasta
-// que reciba una señal de parada a través de QuitChan.
+// que se cierre QuitChan.
 func (w *Worker) Start() {
 	go func() {
+		defer close(w.stopped)
+		defer w.Logger.Info("worker stopped")
 		for {
-			w.WorkerPool <- w.JobQueue // Registra el canal de trabajo del trabajador en el pool.
+			select {
+			case w.WorkerPool <- w.JobQueue: // Registra el canal de trabajo del trabajador en el pool.
+			case <-w.QuitChan:
+				return
+			}
 			select {
 			case job := <-w.JobQueue: // Espera a recibir un trabajo del canal de trabajo.
-				fmt.Printf("👷 Worker %d received job: %s with number: %d\n", w.Id, job.Name, job.Number)
-				fib := Fibonacci(job.Number) // Calcula el número de Fibonacci para el trabajo recibido.
-				time.Sleep(job.Delay)        // Simula el procesamiento del trabajo con un retraso.
-				fmt.Printf("✅ Worker %d processed job: %s with number: %d → Fibonacci: %d\n", w.Id, job.Name, job.Number, fib)
+				w.process(job)
+				if w.Retire != nil && w.Retire(w) {
+					w.Logger.Debug("worker retiring")
+					return
+				}
 			case <-w.QuitChan: // Escucha si se recibe una señal para detener el trabajador.
-				fmt.Printf("🛑 Worker %d is stopping.\n", w.Id)
+				w.Logger.Debug("worker stopping")
 				return // Sale de la goroutine y detiene el trabajador.
 			}
 		}
 	}()
 }
 
-// Stop envía una señal de parada al worker.
-// El método no bloquea y el worker se detendrá de forma asíncrona.
-// También cierra el canal de trabajos del worker para liberar recursos.
-func (w *Worker) Stop() {
-	go func() {
-		w.QuitChan <- true // Envía una señal para detener el trabajador.
-		close(w.JobQueue)  // Cierra el canal de trabajo del trabajador.
-		fmt.Printf("🛑 Worker %d has stopped.\n", w.Id)
+// process ejecuta un trabajo y registra en el Store cada transición de estado.
+// Si el intento falla, incluso por un pánico, se entrega a OnFailure y el
+// worker sigue disponible para nuevos trabajos.
+func (w *Worker) process(job Job) {
+	if job.Context().Err() != nil {
+		return // El trabajo se canceló o expiró antes de llegar al worker.
+	}
+
+	w.setCurrent(&job)
+	defer w.setCurrent(nil)
+	defer w.processed.Add(1)
+
+	logger := w.Logger.With(jobAttrs(job)...).With(logAttempt, job.Attempt)
+	logger.Info("job started")
+	w.Store.MarkRunning(job.ID, w.Id)
+
+	start := time.Now()
+	result, err := w.run(job)
+	if err != nil && job.Context().Err() != nil {
+		logger.Info("job aborted", logDuration, time.Since(start), logError, err)
+		return // El Store ya refleja la cancelación o la expiración.
+	}
+	if err != nil {
+		logger.Warn("job attempt failed", logDuration, time.Since(start), logError, err)
+		if w.OnFailure != nil {
+			w.OnFailure(job, err)
+		} else {
+			w.Store.MarkFailed(job.ID, err.Error())
+		}
+		return
+	}
+
+	w.Store.MarkDone(job.ID, result)
+	logger.Info("job done", logDuration, time.Since(start), "result", abbreviate(result))
+}
+
+// run ejecuta un intento del trabajo, limitado por el AttemptTimeout de su
+// política de reintentos, y devuelve el resultado en decimal. Un pánico
+// durante el cálculo se devuelve como error para que el trabajo pueda
+// reintentarse.
+func (w *Worker) run(job Job) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
 	}()
+
+	ctx := job.Context()
+	if job.Retry.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, job.Retry.AttemptTimeout, ErrAttemptTimedOut)
+		defer cancel()
+	}
+	result, err = w.fibonacci(ctx, job.Number) // Calcula el número de Fibonacci para el trabajo recibido.
+	if err == nil {
+		err = sleepContext(ctx, job.Delay) // Simula el procesamiento del trabajo con un retraso.
+	}
+	return result, err
+}
+
+// fibonacci devuelve en decimal el n-ésimo número de Fibonacci, consultando
+// antes la caché de resultados y guardando en ella los que calcula.
+func (w *Worker) fibonacci(ctx context.Context, n int) (string, error) {
+	if w.Cache != nil {
+		if result, ok := w.Cache.Get(n); ok {
+			return result, nil
+		}
+	}
+	fib, err := Fibonacci(ctx, n)
+	if err != nil {
+		return "", err
+	}
+	result := fib.String()
+	if w.Cache != nil {
+		w.Cache.Add(n, result)
+	}
+	return result, nil
+}
+
+// sleepContext espera la duración indicada o hasta que se cancele ctx,
+// en cuyo caso devuelve la causa de la cancelación.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// setCurrent registra el trabajo que el worker está procesando.
+func (w *Worker) setCurrent(job *Job) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current = job
+	w.busySince = time.Now()
+}
+
+// CurrentJob devuelve el trabajo que el worker está procesando.
+// El segundo valor es false si el worker está libre.
+func (w *Worker) CurrentJob() (Job, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current == nil {
+		return Job{}, false
+	}
+	return *w.current, true
+}
+
+// BusyFor devuelve cuánto lleva el worker con su trabajo actual, o cero si
+// está libre.
+func (w *Worker) BusyFor() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current == nil {
+		return 0
+	}
+	return time.Since(w.busySince)
+}
+
+// WorkerStatus describe el estado de un worker del pool.
+type WorkerStatus struct {
+	ID            int    `json:"id"`
+	State         string `json:"state"` // idle, busy o stopping
+	CurrentJob    string `json:"current_job,omitempty"`
+	JobsProcessed uint64 `json:"jobs_processed"`
+}
+
+// Status devuelve el estado actual del worker.
+func (w *Worker) Status() WorkerStatus {
+	status := WorkerStatus{ID: w.Id, State: "idle", JobsProcessed: w.processed.Load()}
+	if job, ok := w.CurrentJob(); ok {
+		status.State = "busy"
+		status.CurrentJob = job.ID
+	}
+	select {
+	case <-w.QuitChan:
+		status.State = "stopping"
+	default:
+	}
+	return status
+}
+
+// Stop envía una señal de parada al worker cerrando QuitChan. Puede
+// llamarse más de una vez.
+//
+// El método no bloquea: si el worker está procesando un trabajo, lo termina
+// antes de detenerse. Mientras el dispatcher siga despachando, el canal del
+// worker debe retirarse antes del WorkerPool para que no reciba más trabajos.
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.QuitChan)
+	})
 }
 
 // Dispatcher gestiona un pool de workers y distribuye trabajos entre ellos.
-// Actúa como coordinador central que recibe trabajos del JobQueue
-// y los asigna a workers disponibles a través del WorkerPool.
+// Actúa como coordinador central que entrega a cada worker disponible
+// el trabajo de mayor prioridad pendiente en el JobQueue.
 type Dispatcher struct {
-	MaxWorkers int           // Número máximo de workers en el pool
-	WorkerPool chan chan Job // Canal para comunicación con workers disponibles
-	JobQueue   chan Job      // Canal para recibir trabajos a procesar
+	MaxWorkers int            // Número máximo de workers en el pool
+	Scaling    *ScalingPolicy // Escalado automático del pool, nil para mantener MaxWorkers fijos
+	WorkerPool chan chan Job  // Canal para comunicación con workers disponibles
+	JobQueue   *JobQueue      // Cola priorizada de trabajos a procesar
+	Store      *JobStore      // Registro compartido con los workers
+	WAL        *WAL           // Log donde se anotan los trabajos aceptados, nil si no hay persistencia
+
+	RetryPolicy RetryPolicy        // Política de reintentos por defecto de los trabajos
+	DeadLetters *DeadLetterQueue   // Trabajos fallidos tras agotar sus reintentos
+	OnScale     func(ScalingEvent) // Recibe cada cambio del número de workers, o nil
+	Cache       *ResultCache       // Caché de resultados compartida por los workers, o nil
+	Coalesce    bool               // Comparte una sola ejecución entre trabajos idénticos en curso
+	Coalesced   Counter            // Trabajos que se unieron a la ejecución de otro idéntico
+	Logger      *slog.Logger       // Logger del dispatcher y de sus workers
+
+	quit chan struct{} // Se cierra para interrumpir el despacho
+	done chan struct{} // Se cierra cuando Dispatch termina
+
+	workersMu    sync.Mutex // Protege los campos de workers
+	workers      []*Worker  // Workers en funcionamiento
+	nextWorkerID int        // ID del próximo worker creado
+	retiring     int        // Workers ocupados que se retirarán al terminar su trabajo
+
+	scalerQuit chan struct{} // Se cierra para detener el escalado automático
+	scalerDone chan struct{} // Se cierra cuando termina el escalado automático
+	lastBusy   time.Time     // Última vez que el escalado vio trabajos en cola
+
+	flightMu sync.Mutex            // Protege los campos de ejecuciones compartidas
+	flights  map[flightKey]*flight // Ejecuciones en curso por entrada
+	leaders  map[string]*flight    // Ejecuciones en curso por ID del líder
+
+	promoting sync.WaitGroup // Líderes promovidos que todavía se están encolando
+
+	retryMu          sync.Mutex              // Protege los campos de reintentos
+	retrying         map[string]pendingRetry // Reintentos programados por ID de trabajo
+	stopping         bool                    // Indica que ya no se programan reintentos
+	abandonedRetries []Job                   // Reintentos que no llegaron a encolarse por la parada
+	retries          sync.WaitGroup          // Reintentos programados cuyo temporizador no ha terminado
 }
 
 // NewDispatcher crea una nueva instancia de Dispatcher.
 //
 // Parámetros:
-//   - jobQueue: Canal donde se recibirán los trabajos a procesar
+//   - jobQueue: Cola priorizada donde se recibirán los trabajos a procesar
 //   - maxWorkers: Número máximo de workers que manejará el dispatcher
+//   - store: Registro donde los workers reportarán el estado de los trabajos
+//   - logger: Logger del dispatcher y de sus workers, o nil para el de slog por defecto
 //
 // Retorna:
 //   - *Dispatcher: Nueva instancia de dispatcher configurada
-func NewDispatcher(jobQueue chan Job, maxWorkers int) *Dispatcher {
-	return &Dispatcher{
-		JobQueue:   jobQueue,
-		MaxWorkers: maxWorkers,
-		WorkerPool: make(chan chan Job, maxWorkers),
+func NewDispatcher(jobQueue *JobQueue, maxWorkers int, store *JobStore, logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		Logger:      loggerOrDefault(logger),
+		JobQueue:    jobQueue,
+		MaxWorkers:  maxWorkers,
+		Store:       store,
+		WorkerPool:  make(chan chan Job, maxWorkers),
+		DeadLetters: NewDeadLetterQueue(),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+		scalerQuit:  make(chan struct{}),
+		scalerDone:  make(chan struct{}),
+		flights:     make(map[flightKey]*flight),
+		leaders:     make(map[string]*flight),
+		retrying:    make(map[string]pendingRetry),
+	}
+	store.Observe(d.observeQueue) // Libera el hueco de los trabajos cancelados o expirados en cola.
+	return d
+}
+
+// Enqueue registra un trabajo en el Store y lo añade al JobQueue, esperando
+// como máximo wait a que haya espacio. Con wait igual a cero no bloquea. Si
+// hay WAL, el trabajo se anota en disco antes de registrarse. Con Coalesce,
+// un trabajo idéntico a otro en curso no se encola: espera el resultado de
+// ese otro.
+//
+// El trabajo solo se registra, y se notifica a los observadores del Store,
+// una vez que tiene hueco en la cola: un trabajo rechazado no deja rastro.
+//
+// Retorna ErrQueueFull si la cola sigue llena al agotar la espera,
+// ErrQueueClosed si el dispatcher ya se está deteniendo o el error de
+// escritura del WAL. En todos esos casos el contexto del trabajo se cancela
+// con el error como causa.
+func (d *Dispatcher) Enqueue(job Job, wait time.Duration) error {
+	if err := d.JobQueue.Reserve(1, wait); err != nil {
+		job.release(err)
+		return err
+	}
+	return d.accept([]Job{job})
+}
+
+// accept anota en el WAL, registra en el Store y encola trabajos para los que
+// ya se reservó hueco en el JobQueue. Con Coalesce, los idénticos a uno en
+// curso se unen a su ejecución y devuelven su hueco. Si la escritura del WAL
+// falla, devuelve los huecos y cancela los trabajos sin registrar ninguno.
+func (d *Dispatcher) accept(jobs []Job) error {
+	if d.WAL != nil {
+		if err := d.WAL.Append(jobs...); err != nil {
+			d.JobQueue.Release(len(jobs))
+			releaseJobs(jobs, err)
+			return err
+		}
 	}
+
+	leaders := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		d.Store.Add(job)
+		if !d.joinFlight(job) {
+			leaders = append(leaders, job)
+		}
+	}
+	d.JobQueue.Release(len(jobs) - len(leaders))
+	d.JobQueue.PushReserved(leaders)
+	return nil
 }
 
-// Dispatch procesa trabajos del JobQueue y los distribuye a workers disponibles.
+// Requeue registra y vuelve a encolar trabajos recuperados del WAL, esperando
+// a que haya espacio en la cola si es necesario. Se detiene si el dispatcher
+// empieza a detenerse; los trabajos restantes siguen pendientes en el WAL.
 // Este método bloquea y debe ejecutarse en una goroutine separada.
-// Continúa procesando trabajos hasta que se cierre el JobQueue.
+func (d *Dispatcher) Requeue(jobs []Job) {
+	for i, job := range jobs {
+		err := d.JobQueue.Reserve(1, requeueWait)
+		for errors.Is(err, ErrQueueFull) {
+			err = d.JobQueue.Reserve(1, requeueWait)
+		}
+		if err != nil {
+			d.Logger.Warn("stopped replaying WAL", "pending", len(jobs)-i)
+			releaseJobs(jobs[i:], err)
+			return
+		}
+		d.Store.Add(job)
+		d.JobQueue.PushReserved([]Job{job})
+		d.Logger.Info("job replayed", jobAttrs(job)...)
+	}
+}
+
+// releaseJobs cancela el contexto de los trabajos rechazados con err como causa.
+func releaseJobs(jobs []Job, err error) {
+	for _, job := range jobs {
+		job.release(err)
+	}
+}
+
+// requeueWait es cuánto espera Requeue por un hueco antes de reintentar.
+const requeueWait = time.Second
+
+// EstimateWait estima cuánto tardará en liberarse espacio en el JobQueue a
+// partir de su ocupación actual y la duración media de los trabajos.
+func (d *Dispatcher) EstimateWait() time.Duration {
+	pending := d.JobQueue.Len() + 1
+	workers := max(d.WorkerCount(), 1)
+	return d.Store.AverageDuration() * time.Duration(pending) / time.Duration(workers)
+}
+
+// Dispatch espera a que haya un worker disponible y le entrega el trabajo de
+// mayor prioridad pendiente en el JobQueue. Cada trabajo permanece en la cola
+// hasta ese momento, de modo que un trabajo prioritario que llegue después
+// adelanta a los que ya esperaban.
+// Este método bloquea y debe ejecutarse en una goroutine separada.
+// Continúa procesando trabajos hasta que se cierre y vacíe el JobQueue o se
+// interrumpa el despacho desde Stop.
 func (d *Dispatcher) Dispatch() {
-	for job := range d.JobQueue { // Itera sobre los trabajos recibidos en el canal de trabajos.
-		go func(job Job) {
-			workerJobQueue := <-d.WorkerPool // Obtiene un canal de trabajo de un trabajador disponible.
-			workerJobQueue <- job            // Envía el trabajo al canal del trabajador.
-		}(job)
+	defer close(d.done)
+	for {
+		var workerJobQueue chan Job
+		select {
+		case workerJobQueue = <-d.WorkerPool: // Obtiene un canal de trabajo de un trabajador disponible.
+		case <-d.quit:
+			return
+		}
+
+		job, ok := d.nextJob()
+		if !ok {
+			d.WorkerPool <- workerJobQueue // Devuelve el worker al pool sin trabajo.
+			return
+		}
+		workerJobQueue <- job // Envía el trabajo al canal del trabajador.
+	}
+}
+
+// nextJob extrae del JobQueue el siguiente trabajo vigente, descartando los
+// que se cancelaron o expiraron mientras esperaban en la cola.
+func (d *Dispatcher) nextJob() (Job, bool) {
+	for {
+		job, ok := d.JobQueue.Pop(d.quit)
+		if !ok || job.Context().Err() == nil {
+			return job, ok
+		}
+	}
+}
+
+// observeQueue se registra con JobStore.Observe. Retira de la cola los
+// trabajos cancelados o expirados mientras esperaban, para que no ocupen su
+// hueco hasta que nextJob los descarte.
+func (d *Dispatcher) observeQueue(rec JobRecord) {
+	if rec.Status == StatusCancelled || rec.Status == StatusTimedOut {
+		d.JobQueue.Remove(rec.ID)
 	}
 }
 
 // Run inicializa y pone en funcionamiento el dispatcher.
-// Crea el número especificado de workers, los inicia y comienza
-// a despachar trabajos. Este método no bloquea.
+// Crea e inicia MaxWorkers workers, o Scaling.MinWorkers si el escalado
+// automático está activo, y comienza a despachar trabajos.
+// Este método no bloquea.
 func (d *Dispatcher) Run() {
-	for i := 0; i < d.MaxWorkers; i++ {
-		worker := NewWorker(i, d.WorkerPool) // Crea un nuevo trabajador.
-		worker.Start()                       // Inicia el trabajador.
+	if d.Coalesce {
+		d.Store.Observe(d.observeFlight) // Entrega los resultados compartidos.
+	}
+	if d.Scaling != nil {
+		d.addWorkers(d.Scaling.MinWorkers)
+		go d.autoscale() // Ajusta el número de workers según la carga.
+	} else {
+		d.addWorkers(d.MaxWorkers)
+		close(d.scalerDone)
 	}
 	go d.Dispatch() // Comienza a despachar trabajos a los trabajadores.
 }
 
-func Fibonacci(n int) int {
-	if n <= 1 {
-		return n
+// WorkerCount devuelve el número de workers del pool.
+func (d *Dispatcher) WorkerCount() int {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	return len(d.workers)
+}
+
+// IdleWorkers devuelve el número de workers que no están procesando un trabajo.
+func (d *Dispatcher) IdleWorkers() int {
+	d.workersMu.Lock()
+	defer d.workersMu.Unlock()
+	idle := 0
+	for _, worker := range d.workers {
+		if _, busy := worker.CurrentJob(); !busy {
+			idle++
+		}
+	}
+	return idle
+}
+
+// Stop detiene el dispatcher de forma ordenada:
+//  1. Deja de aceptar trabajos nuevos cerrando el JobQueue, detiene el
+//     escalado automático y cancela los reintentos programados, que se
+//     devuelven como abandonados.
+//  2. Espera a que los trabajos encolados se entreguen a los workers.
+//  3. Detiene todos los workers y espera a que terminen su trabajo actual.
+//
+// Si ctx expira antes de completar el drenado, los trabajos que sigan en la
+// cola o en ejecución se devuelven como abandonados, junto con los que
+// esperaban compartir su ejecución. Los reintentos cuyo temporizador venció
+// durante la parada y los líderes promovidos que no llegaron a encolarse
+// también se devuelven como abandonados.
+func (d *Dispatcher) Stop(ctx context.Context) []Job {
+	d.JobQueue.Close()
+	close(d.scalerQuit)
+	<-d.scalerDone
+	abandoned := d.abandonRetries()
+
+	select {
+	case <-d.done: // Todos los trabajos encolados llegaron a un worker.
+	case <-ctx.Done():
+		close(d.quit)
+		<-d.done
+	}
+
+	for _, job := range d.JobQueue.Drain() { // Recoge lo que quedó en la cola tras el plazo.
+		if job.Context().Err() == nil {
+			abandoned = append(abandoned, job)
+		}
+	}
+
+	d.workersMu.Lock()
+	workers := d.workers
+	d.workersMu.Unlock()
+	for _, worker := range workers {
+		worker.Stop()
+	}
+	for _, worker := range workers {
+		select {
+		case <-worker.stopped:
+		case <-ctx.Done():
+			if job, ok := worker.CurrentJob(); ok {
+				abandoned = append(abandoned, job)
+			}
+		}
+	}
+	d.promoting.Wait() // Con la cola cerrada, los líderes pendientes no tardan en fallar.
+	abandoned = append(abandoned, d.abandonFlights()...)
+	d.retries.Wait() // Los temporizadores que ya vencieron anotan su trabajo al fallar.
+	return append(abandoned, d.takeAbandonedRetries()...)
+}
+
+// ErrNegativeNumber se devuelve al calcular el Fibonacci de un número negativo.
+var ErrNegativeNumber = errors.New("fibonacci is not defined for negative numbers")
+
+// Fibonacci calcula el n-ésimo número de Fibonacci con precisión arbitraria
+// mediante fast doubling, que necesita O(log n) multiplicaciones:
+//
+//	F(2k)   = F(k) * (2*F(k+1) - F(k))
+//	F(2k+1) = F(k+1)² + F(k)²
+//
+// Recorre los bits de n de mayor a menor manteniendo el par (F(k), F(k+1)).
+// El cálculo se interrumpe si ctx se cancela, devolviendo la causa.
+func Fibonacci(ctx context.Context, n int) (*big.Int, error) {
+	if n < 0 {
+		return nil, ErrNegativeNumber
+	}
+	a, b := big.NewInt(0), big.NewInt(1) // F(0) y F(1)
+	for i := bits.Len(uint(n)) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		c := new(big.Int).Lsh(b, 1) // F(2k)
+		c.Sub(c, a).Mul(c, a)
+		d := new(big.Int).Mul(a, a) // F(2k+1)
+		d.Add(d, b.Mul(b, b))
+		if n>>i&1 == 0 {
+			a, b = c, d
+		} else {
+			a, b = d, c.Add(c, d)
+		}
+	}
+	return a, nil
+}
+
+// abbreviate acorta los resultados muy largos para mostrarlos en consola.
+func abbreviate(result string) string {
+	const keep = 12
+	if len(result) <= 2*keep+3 {
+		return result
 	}
-	return Fibonacci(n-1) + Fibonacci(n-2)
+	return fmt.Sprintf("%s...%s (%d digits)", result[:keep], result[len(result)-keep:], len(result))
+}
+
+// maxWait limita el tiempo que una solicitud puede esperar el resultado de un trabajo.
+const maxWait = 30 * time.Second
+
+// RequestOptions agrupa la configuración de RequestHandler.
+type RequestOptions struct {
+	EnqueueTimeout time.Duration // Espera máxima por un hueco en la cola llena
+	MaxValue       int           // Valor máximo admitido para value
+	ServeCached    bool          // Responde los aciertos de caché sin pasar por el Dispatcher
+	MaxBatch       int           // Número máximo de trabajos en un lote
+	Callbacks      bool          // Admite callback_url; requiere un secreto para firmar
 }
 
 // RequestHandler maneja las solicitudes HTTP para crear trabajos de Fibonacci.
-// Acepta solicitudes POST con parámetros de formulario y crea trabajos
-// que se envían al canal de trabajos para ser procesados por los workers.
+// Acepta solicitudes POST con un cuerpo JSON (Content-Type: application/json)
+// o con parámetros de formulario, y crea trabajos que se envían al canal de
+// trabajos para ser procesados por los workers.
+// Si la cola sigue llena tras opts.EnqueueTimeout se responde 503 con una
+// cabecera Retry-After estimada a partir de la ocupación de la cola.
+// Con opts.ServeCached, si el resultado ya está en la caché del dispatcher
+// el trabajo se da por terminado sin encolarlo y se responde 200 con el
+// registro y la cabecera X-Cache: HIT.
+// Cada trabajo recibe un ID generado por el servidor; la respuesta contiene
+// el registro del trabajo y la cabecera Location apunta a él. Los errores se
+// devuelven como un objeto JSON con un mensaje por cada campo inválido.
 //
-// Parámetros esperados en la solicitud:
+// Campos de la solicitud:
 //   - delay: Duración del delay de procesamiento (ej: "2s", "500ms")
-//   - value: Número entero para calcular su Fibonacci
+//   - value: Número entero no negativo para calcular su Fibonacci, como
+//     máximo opts.MaxValue
 //   - name: Nombre identificativo del trabajo
-func RequestHandler(w http.ResponseWriter, r *http.Request, jobQueue chan Job) {
+//   - priority: Opcional. "high", "normal" (por defecto) o "low"
+//   - timeout: Opcional. Tiempo máximo desde la aceptación para terminar el
+//     trabajo (ej: "10s"). Excluyente con deadline.
+//   - deadline: Opcional. Fecha límite absoluta en formato RFC 3339.
+//   - max_attempts: Opcional. Número máximo de intentos si el trabajo falla.
+//   - backoff: Opcional. Espera base entre reintentos (ej: "500ms").
+//   - attempt_timeout: Opcional. Duración máxima de cada intento.
+//   - callback_url: Opcional. URL a la que se envía el resultado firmado al
+//     terminar el trabajo (requiere opts.Callbacks).
+//   - wait: Opcional, también en la URL. Tiempo máximo a esperar el resultado
+//     (ej: "5s"). Si el trabajo termina a tiempo se responde 200 con el
+//     resultado; si no, 202 con el registro para consultarlo después.
+func RequestHandler(w http.ResponseWriter, r *http.Request, dispatcher *Dispatcher, store *JobStore, opts RequestOptions) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Allow", http.MethodPost)
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, http.MethodPost)
 		return
 	}
 
-	delay, err := time.ParseDuration(r.FormValue("delay"))
+	req, errs, err := decodeJobRequest(w, r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit), nil)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid delay parameter", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body: "+err.Error(), nil)
 		return
 	}
+	job, wait, ok := req.Job(opts, dispatcher.RetryPolicy, errs)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid job request", errs)
+		return
+	}
+	job.Owner = requestOwner(r)
 
-	value, err := strconv.Atoi(r.FormValue("value"))
-	if err != nil {
-		http.Error(w, "Invalid value parameter", http.StatusBadRequest)
+	if opts.ServeCached && dispatcher.Cache != nil {
+		if result, ok := dispatcher.Cache.Lookup(job.Number); ok {
+			store.Add(job)
+			store.MarkDone(job.ID, result)
+			dispatcher.Logger.Info("job served from cache", jobAttrs(job)...)
+			rec, _ := store.Get(job.ID)
+			w.Header().Set("Location", "/fibonacci/"+job.ID)
+			w.Header().Set("X-Cache", "HIT")
+			writeJSON(w, http.StatusOK, rec)
+			return
+		}
+	}
+
+	if err := dispatcher.Enqueue(job, opts.EnqueueTimeout); err != nil {
+		writeEnqueueError(w, dispatcher, job, err)
 		return
 	}
 
-	name := r.FormValue("name")
-	if name == "" {
-		http.Error(w, "Name parameter is required", http.StatusBadRequest)
+	w.Header().Set("Location", "/fibonacci/"+job.ID)
+	if wait == 0 {
+		rec, _ := store.Get(job.ID)
+		writeJSON(w, http.StatusCreated, rec)
 		return
 	}
 
-	job := Job{
-		Number: value,
-		Name:   name,
-		Delay:  delay,
+	done, _ := store.Done(job.ID)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done: // El worker terminó dentro del tiempo de espera.
+		rec, _ := store.Get(job.ID)
+		writeJSON(w, http.StatusOK, rec)
+	case <-timer.C: // Se agotó la espera; el cliente deberá consultar el estado.
+		rec, _ := store.Get(job.ID)
+		writeJSON(w, http.StatusAccepted, rec)
+	case <-r.Context().Done(): // El cliente cerró la conexión.
+	}
+}
+
+// writeEnqueueError responde al error devuelto por Dispatcher.Enqueue: 503
+// con Retry-After si la cola está llena, 503 si el servidor se está
+// deteniendo y 500 en cualquier otro caso.
+func writeEnqueueError(w http.ResponseWriter, dispatcher *Dispatcher, job Job, err error) {
+	status, apiErr := enqueueAPIError(err)
+	switch apiErr.Code {
+	case "queue_full":
+		retryAfter := int(math.Ceil(dispatcher.EstimateWait().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	case "internal_error":
+		dispatcher.Logger.Error("could not accept job", append(jobAttrs(job), logError, err)...)
+	}
+	writeError(w, status, apiErr.Code, apiErr.Message, nil)
+}
+
+// enqueueAPIError traduce un error de Dispatcher.Enqueue al código de estado
+// y al error de la API correspondientes.
+func enqueueAPIError(err error) (int, *APIError) {
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable, &APIError{Code: "queue_full", Message: "Job queue is full, retry later"}
+	case errors.Is(err, ErrQueueClosed):
+		return http.StatusServiceUnavailable, &APIError{Code: "shutting_down", Message: "Server is shutting down"}
+	default:
+		return http.StatusInternalServerError, &APIError{Code: "internal_error", Message: "Could not accept job"}
+	}
+}
+
+// JobHandler maneja las solicitudes sobre un trabajo concreto:
+//   - GET /fibonacci/{id}: devuelve en JSON el estado, las marcas de tiempo
+//     y el resultado del trabajo.
+//   - DELETE /fibonacci/{id}: cancela el trabajo si sigue en cola o en
+//     ejecución y devuelve su registro en estado cancelled.
+func JobHandler(w http.ResponseWriter, r *http.Request, store *JobStore, logger *slog.Logger) {
+	id := strings.TrimPrefix(r.URL.Path, "/fibonacci/")
+
+	switch r.Method {
+	case http.MethodGet:
+		rec, ok := store.Get(id)
+		if !ok || !visibleTo(r, rec.Owner) {
+			writeError(w, http.StatusNotFound, "not_found", "Job not found", nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, rec)
+
+	case http.MethodDelete:
+		if rec, ok := store.Get(id); ok && !visibleTo(r, rec.Owner) {
+			writeError(w, http.StatusNotFound, "not_found", "Job not found", nil) // No revela trabajos ajenos.
+			return
+		}
+		rec, err := store.Cancel(id)
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "Job not found", nil)
+		case errors.Is(err, ErrJobFinished):
+			writeJSON(w, http.StatusConflict, rec)
+		default:
+			logger.Info("job cancelled", recordAttrs(rec)...)
+			writeJSON(w, http.StatusOK, rec)
+		}
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
 	}
-	jobQueue <- job
+}
 
-	w.WriteHeader(http.StatusCreated)
+// writeJSON serializa v como JSON y lo escribe en la respuesta con el código indicado.
+// Los valores que se escriben siempre se pueden serializar, así que el
+// encoder solo falla si el cliente ya cerró la conexión y el error se ignora.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
 // El servidor:
-//   - Crea un pool de 4 workers
-//   - Configura una cola de trabajos con capacidad para 20 trabajos
-//   - Inicia un servidor HTTP en el puerto 8081
+//   - Lee la configuración de los flags, las variables de entorno y el
+//     archivo de configuración (ver Config), y termina si no es válida
+//   - Crea un pool de -max-workers workers (4 por defecto), o entre
+//     -min-workers y -max-workers con escalado automático según la carga
+//   - Configura una cola de trabajos con capacidad para -queue-size trabajos,
+//     más -queue-backlog trabajos de lotes
+//   - Inicia un servidor HTTP en -addr (:8081 por defecto), con TLS si se
+//     indican -tls-cert y -tls-key, y con mTLS si además se indica
+//     -tls-client-ca, que requiere -api-keys
 //   - Expone el endpoint POST /fibonacci para recibir trabajos
+//   - Expone el endpoint GET /fibonacci/{id} para consultar su estado
+//   - Expone el endpoint DELETE /fibonacci/{id} para cancelarlo
+//   - Expone los endpoints POST /fibonacci/batch y GET /fibonacci/batch/{id}
+//     para enviar lotes de trabajos y consultar su progreso
+//   - Expone los endpoints GET /events y GET /fibonacci/{id}/events con los
+//     cambios de estado de los trabajos como Server-Sent Events
+//   - Expone los endpoints GET /healthz y GET /readyz para el orquestador
+//   - Expone el endpoint GET /metrics con métricas en formato Prometheus
+//   - Expone los endpoints GET y PUT /admin/workers para administrar el pool
+//   - Con -api-keys exige una API key o un certificado de cliente con el
+//     permiso adecuado en todos los endpoints salvo /healthz y /readyz
+//   - Al recibir SIGINT o SIGTERM deja de aceptar trabajos, espera
+//     -shutdown-delay, deja de aceptar solicitudes, drena la cola hasta el
+//     plazo indicado por -shutdown-timeout y detiene los workers
 func main() {
-	const (
-		maxWorkers   = 4
-		maxQueueSize = 20
-		port         = ":8081"
-	)
+	cfg, err := LoadConfig(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	logger, _ := NewLogger(os.Stderr, cfg.LogFormat, cfg.LogLevel) // Validate ya comprobó el formato y el nivel.
+	slog.SetDefault(logger)
+	fatal := func(msg string, args ...any) {
+		logger.Error(msg, args...)
+		os.Exit(1)
+	}
+	logger.Info("configuration loaded", "file", cfg.File, "config", cfg)
+
+	jobQueue := NewJobQueue(cfg.QueueSize, cfg.QueueBacklog, cfg.PriorityAging) // Cola priorizada de trabajos.
+
+	store := NewJobStore() // Registro consultable de los trabajos aceptados.
+	store.Retention = cfg.JobRetention
+
+	dispatcher := NewDispatcher(jobQueue, cfg.MaxWorkers, store, logger) // Crea un despachador con el canal de trabajos y el número máximo de trabajadores.
+	if cfg.CacheEntries > 0 {
+		dispatcher.Cache = NewResultCache(cfg.CacheEntries, cfg.CacheBytes) // Resultados compartidos por los workers.
+	}
+	dispatcher.Coalesce = cfg.Coalesce
+	dispatcher.RetryPolicy = RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		Backoff:        cfg.RetryBackoff,
+		MaxBackoff:     cfg.RetryMaxBackoff,
+		AttemptTimeout: cfg.AttemptTimeout,
+	}
+	dispatcher.DeadLetters.Capacity = cfg.DeadLetterCapacity
+	dispatcher.DeadLetters.TTL = cfg.DeadLetterTTL
+	if cfg.MinWorkers > 0 && cfg.MinWorkers < cfg.MaxWorkers {
+		dispatcher.Scaling = &ScalingPolicy{
+			MinWorkers: cfg.MinWorkers,
+			Interval:   cfg.ScaleInterval,
+			Backlog:    cfg.ScaleBacklog,
+			QueueWait:  cfg.ScaleQueueWait,
+			Cooldown:   cfg.ScaleCooldown,
+		}
+	}
 
-	jobQueue := make(chan Job, maxQueueSize) // Canal para recibir trabajos.
+	metrics := NewMetrics(dispatcher) // Métricas en formato Prometheus.
+	store.Observe(metrics.Observe)
+	dispatcher.OnScale = metrics.ObserveScaling
+
+	events := NewEventBroker(cfg.EventBuffer) // Streams de eventos de los trabajos.
+	events.Logger = logger
+	store.Observe(events.Observe)
+	metrics.Events = events
+
+	var webhooks *WebhookNotifier
+	if cfg.WebhookSecret != "" {
+		webhooks = NewWebhookNotifier(store, []byte(cfg.WebhookSecret), RetryPolicy{
+			MaxAttempts:    cfg.WebhookAttempts,
+			Backoff:        cfg.WebhookBackoff,
+			MaxBackoff:     cfg.RetryMaxBackoff,
+			AttemptTimeout: cfg.WebhookTimeout,
+		}) // Entrega los resultados a las callback_url de los trabajos.
+		webhooks.Logger = logger
+		webhooks.AllowedNetworks, _ = ParseNetworks(cfg.WebhookNetworks) // Validate ya comprobó el formato.
+		store.Observe(webhooks.Observe)
+		metrics.Webhooks = webhooks
+	}
+
+	dispatcher.Run() // Inicia el despachador.
+
+	compactionQuit := make(chan struct{})
+	if cfg.WALPath != "" {
+		wal, pending, err := OpenWAL(cfg.WALPath, logger) // Recupera los trabajos aceptados que no terminaron.
+		if err != nil {
+			fatal("could not open WAL", "path", cfg.WALPath, logError, err)
+		}
+		defer wal.Close()
+
+		store.Observe(func(rec JobRecord) {
+			if rec.Status.Terminal() {
+				if err := wal.Finish(rec.ID, rec.Status); err != nil {
+					logger.Error("could not write WAL", append(recordAttrs(rec), logError, err)...)
+				}
+			}
+		})
+		dispatcher.WAL = wal
+		go dispatcher.Requeue(pending)
+		go wal.RunCompaction(cfg.WALCompactInterval, compactionQuit)
+	}
+
+	logger.Info("server starting", "addr", cfg.Addr, "tls", cfg.TLSCert != "", "mtls", cfg.TLSClientCA != "")
+	requestOptions := RequestOptions{
+		EnqueueTimeout: cfg.EnqueueTimeout,
+		MaxValue:       cfg.MaxValue,
+		ServeCached:    cfg.ServeCached,
+		MaxBatch:       cfg.MaxBatch,
+		Callbacks:      webhooks != nil,
+	}
+	var auth *Authenticator // Sin archivo de API keys no se exige autenticación.
+	if cfg.APIKeys != "" {
+		if auth, err = LoadAPIKeys(cfg.APIKeys); err != nil {
+			fatal("could not load API keys", "path", cfg.APIKeys, logError, err)
+		}
+	}
 
-	dispatcher := NewDispatcher(jobQueue, maxWorkers) // Crea un despachador con el canal de trabajos y el número máximo de trabajadores.
-	dispatcher.Run()                                  // Inicia el despachador.
+	batches := NewBatchStore()          // Lotes enviados a POST /fibonacci/batch.
+	store.ObserveForget(batches.forget) // Los lotes caducan con sus trabajos.
+	submitJobs := func(w http.ResponseWriter, r *http.Request) {
+		RequestHandler(w, r, dispatcher, store, requestOptions) // Maneja las solicitudes HTTP para crear trabajos.
+	}
+	submitBatch := func(w http.ResponseWriter, r *http.Request) {
+		BatchHandler(w, r, dispatcher, store, batches, requestOptions) // Crea un lote de trabajos.
+	}
+	if cfg.RateLimit > 0 {
+		overrides, _ := ParseRateLimits(cfg.RateLimitOverrides) // Validate ya comprobó el formato.
+		limiter := NewRateLimiter(RateLimit{Rate: cfg.RateLimit, Burst: cfg.RateBurst}, overrides, cfg.RateLimitIdle)
+		submitJobs, submitBatch = limiter.Handler(submitJobs), limiter.Handler(submitBatch) // Cada cliente tiene su propio límite.
+		metrics.RateLimiter = limiter
+	}
+	readJob := auth.Require(ScopeJobsRead, func(w http.ResponseWriter, r *http.Request) {
+		JobHandler(w, r, store, logger) // Consulta un trabajo.
+	})
+	cancelJob := auth.Require(ScopeJobsSubmit, func(w http.ResponseWriter, r *http.Request) {
+		JobHandler(w, r, store, logger) // Cancela un trabajo.
+	})
+	readEvents := auth.Require(ScopeJobsRead, func(w http.ResponseWriter, r *http.Request) {
+		EventsHandler(w, r, events, store) // Emite los eventos de un trabajo o de todos.
+	})
+	administer := func(next http.HandlerFunc) http.HandlerFunc {
+		return auth.Require(ScopeAdmin, next)
+	}
 
-	fmt.Println("🚀 Starting server on port", port)
-	http.HandleFunc("/fibonacci", func(w http.ResponseWriter, r *http.Request) {
-		RequestHandler(w, r, jobQueue) // Maneja las solicitudes HTTP para crear trabajos.
+	http.HandleFunc("/fibonacci", auth.Require(ScopeJobsSubmit, submitJobs))
+	http.HandleFunc("/fibonacci/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/events"):
+			readEvents(w, r)
+		case r.Method == http.MethodDelete:
+			cancelJob(w, r)
+		default:
+			readJob(w, r)
+		}
+	})
+	http.HandleFunc("/fibonacci/batch", auth.Require(ScopeJobsSubmit, submitBatch))
+	http.HandleFunc("/fibonacci/batch/", auth.Require(ScopeJobsRead, func(w http.ResponseWriter, r *http.Request) {
+		BatchHandler(w, r, dispatcher, store, batches, requestOptions) // Consulta el progreso de un lote.
+	}))
+	http.HandleFunc("/events", readEvents)
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		HealthHandler(w, r) // Indica que el proceso está vivo.
 	})
+	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadinessHandler(w, r, dispatcher, cfg.StuckAfter) // Indica si se aceptan trabajos.
+	})
+	http.HandleFunc("/metrics", administer(func(w http.ResponseWriter, r *http.Request) {
+		MetricsHandler(w, r, metrics) // Expone las métricas para Prometheus.
+	}))
+	http.HandleFunc("/admin/", administer(func(w http.ResponseWriter, r *http.Request) {
+		AdminHandler(w, r, dispatcher) // Consulta o redimensiona el pool de workers.
+	}))
+	http.HandleFunc("/deadletters", administer(func(w http.ResponseWriter, r *http.Request) {
+		DeadLetterHandler(w, r, dispatcher, store) // Lista los trabajos fallidos.
+	}))
+	http.HandleFunc("/deadletters/", administer(func(w http.ResponseWriter, r *http.Request) {
+		DeadLetterHandler(w, r, dispatcher, store) // Consulta, descarta o reenvía un trabajo fallido.
+	}))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Inicia el servidor HTTP y registra cualquier error fatal
-	log.Fatal(http.ListenAndServe(port, nil))
+	// Inicia el servidor HTTP en segundo plano y registra cualquier error fatal
+	server := &http.Server{Addr: cfg.Addr}
+	server.RegisterOnShutdown(events.Close) // Shutdown no espera a los streams de eventos.
+	if cfg.TLSCert != "" {
+		certs, err := NewCertReloader(cfg.TLSCert, cfg.TLSKey, cfg.TLSReloadInterval)
+		if err != nil {
+			fatal("could not load TLS certificate", "cert", cfg.TLSCert, logError, err)
+		}
+		certs.Logger = logger
+		if server.TLSConfig, err = NewTLSConfig(certs, cfg.TLSClientCA); err != nil {
+			fatal("could not configure TLS", logError, err)
+		}
+	}
+	go func() {
+		serve := server.ListenAndServe
+		if server.TLSConfig != nil {
+			serve = func() error { return server.ListenAndServeTLS("", "") } // El certificado lo da TLSConfig.
+		}
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server failed", logError, err)
+		}
+	}()
+
+	<-ctx.Done() // Espera una señal de parada.
+	if cfg.ShutdownDelay > 0 {
+		// Rechaza trabajos nuevos y deja que /readyz falle el tiempo suficiente
+		// para que el balanceador deje de enviar tráfico antes de cerrar.
+		dispatcher.JobQueue.Close()
+		logger.Info("shutting down, waiting before closing the server", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	logger.Info("shutting down, closing HTTP server", "timeout", cfg.ShutdownTimeout)
+
+	serverCtx, cancelServer := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelServer()
+
+	if err := server.Shutdown(serverCtx); err != nil {
+		logger.Error("could not shut down HTTP server", logError, err)
+	}
+
+	// El drenado tiene su propio plazo, para que las solicitudes que esperan
+	// su resultado (wait) no lo consuman mientras se cierra el servidor.
+	logger.Info("shutting down, draining queued jobs", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	abandoned := dispatcher.Stop(shutdownCtx)
+	if webhooks != nil {
+		webhooks.Close(shutdownCtx) // Entrega los callbacks pendientes hasta el mismo plazo.
+	}
+	close(compactionQuit)
+	for _, job := range abandoned {
+		logger.Warn("job abandoned", jobAttrs(job)...)
+	}
+	logger.Info("server stopped", "abandoned", len(abandoned))
 }
@@ -0,0 +1,177 @@
+// Este archivo contiene pruebas unitarias para el ciclo de vida del
+// dispatcher y de sus workers. Los trabajos usan el delay para seguir en
+// ejecución mientras vence el plazo de la detención.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestDispatcherStop verifica que Stop espere a los trabajos encolados y en
+// ejecución y que, si vence el plazo, los devuelva como abandonados.
+func TestDispatcherStop(t *testing.T) {
+	testCases := []struct {
+		name          string
+		delay         time.Duration // Delay de cada trabajo
+		timeout       time.Duration // Plazo de la detención
+		wantAbandoned int
+		wantStatus    JobStatus
+	}{
+		{name: "drains the queue", delay: 0, timeout: time.Second, wantStatus: StatusDone},
+		{name: "deadline expires", delay: 500 * time.Millisecond, timeout: 50 * time.Millisecond, wantAbandoned: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			d := NewDispatcher(NewJobQueue(2, 0, time.Second), 1, store, nil)
+			jobs := []Job{
+				NewJob("first", 10, tc.delay, time.Time{}, PriorityNormal),
+				NewJob("second", 11, tc.delay, time.Time{}, PriorityNormal),
+			}
+			for _, job := range jobs {
+				if err := d.Enqueue(job, 0); err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+			}
+			d.Run()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			abandoned := d.Stop(ctx)
+
+			if len(abandoned) != tc.wantAbandoned {
+				t.Fatalf("Stop() abandoned %d job(s); want %d", len(abandoned), tc.wantAbandoned)
+			}
+			for _, job := range jobs {
+				if tc.wantAbandoned > 0 {
+					if !slices.ContainsFunc(abandoned, func(a Job) bool { return a.ID == job.ID }) {
+						t.Errorf("job %s not reported as abandoned", job.Name)
+					}
+					continue
+				}
+				if rec, _ := store.Get(job.ID); rec.Status != tc.wantStatus {
+					t.Errorf("job %s status = %q; want %q", job.Name, rec.Status, tc.wantStatus)
+				}
+			}
+			if err := d.Enqueue(NewJob("late", 12, 0, time.Time{}, PriorityNormal), 0); !errors.Is(err, ErrQueueClosed) {
+				t.Errorf("Enqueue() after Stop error = %v; want %v", err, ErrQueueClosed)
+			}
+		})
+	}
+}
+
+// TestDispatcherStopReportsPendingWork verifica que Stop devuelva como
+// abandonados los trabajos que no llegan a encolarse porque la cola ya se
+// cerró: un reintento cuyo temporizador vence durante la parada y un
+// seguidor promovido a líder tras cancelarse el líder en ejecución.
+func TestDispatcherStopReportsPendingWork(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(t *testing.T, d *Dispatcher) Job // Devuelve el trabajo que debe abandonarse
+	}{
+		{
+			name: "retry timer fires while stopping",
+			setup: func(t *testing.T, d *Dispatcher) Job {
+				job := NewJob("retry", 10, 0, time.Time{}, PriorityNormal)
+				d.Store.Add(job)
+				d.JobQueue.Close()
+				d.scheduleRetry(job, 0)
+				return job
+			},
+		},
+		{
+			name: "promoted leader cannot be queued",
+			setup: func(t *testing.T, d *Dispatcher) Job {
+				jobs := []Job{
+					NewJob("leader", 40, time.Hour, time.Time{}, PriorityNormal),
+					NewJob("follower", 40, time.Hour, time.Time{}, PriorityNormal),
+				}
+				for _, job := range jobs {
+					if err := d.Enqueue(job, 0); err != nil {
+						t.Fatalf("Enqueue() error = %v", err)
+					}
+				}
+				deadline := time.Now().Add(time.Second)
+				for rec, _ := d.Store.Get(jobs[0].ID); rec.Status != StatusRunning; rec, _ = d.Store.Get(jobs[0].ID) {
+					if time.Now().After(deadline) {
+						t.Fatal("leader did not start")
+					}
+					time.Sleep(time.Millisecond)
+				}
+				d.JobQueue.Close()
+				if _, err := d.Store.Cancel(jobs[0].ID); err != nil {
+					t.Fatalf("Cancel(leader) error = %v", err)
+				}
+				return jobs[1]
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDispatcher(NewJobQueue(2, 0, time.Second), 1, NewJobStore(), nil)
+			d.Coalesce = true
+			d.Run()
+			want := tc.setup(t, d)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			abandoned := d.Stop(ctx)
+
+			if len(abandoned) != 1 || abandoned[0].ID != want.ID {
+				t.Errorf("Stop() abandoned %v; want only %s", abandoned, want.Name)
+			}
+		})
+	}
+}
+
+// TestEnqueueRejectionReleasesJob verifica que un trabajo rechazado por
+// Enqueue o EnqueueAll tenga su contexto cancelado con el error como causa.
+func TestEnqueueRejectionReleasesJob(t *testing.T) {
+	testCases := []struct {
+		name    string
+		enqueue func(d *Dispatcher, job Job) error
+		wantErr error
+	}{
+		{
+			name: "queue full",
+			enqueue: func(d *Dispatcher, job Job) error {
+				d.Enqueue(NewJob("first", 10, 0, time.Time{}, PriorityNormal), 0)
+				return d.Enqueue(job, 0)
+			},
+			wantErr: ErrQueueFull,
+		},
+		{
+			name: "queue closed",
+			enqueue: func(d *Dispatcher, job Job) error {
+				d.JobQueue.Close()
+				return d.Enqueue(job, 0)
+			},
+			wantErr: ErrQueueClosed,
+		},
+		{
+			name: "batch does not fit",
+			enqueue: func(d *Dispatcher, job Job) error {
+				return d.EnqueueAll([]Job{NewJob("first", 10, 0, time.Time{}, PriorityNormal), job}, 0)
+			},
+			wantErr: ErrQueueFull,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, NewJobStore(), nil)
+			job := NewJob("rejected", 11, 0, time.Now().Add(time.Hour), PriorityNormal)
+
+			if err := tc.enqueue(d, job); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("enqueue error = %v; want %v", err, tc.wantErr)
+			}
+			if cause := context.Cause(job.Context()); !errors.Is(cause, tc.wantErr) {
+				t.Errorf("job context cause = %v; want %v", cause, tc.wantErr)
+			}
+		})
+	}
+}
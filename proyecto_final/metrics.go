@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter es un contador monótono seguro para uso concurrente.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc incrementa el contador en uno.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Value devuelve el valor actual del contador.
+func (c *Counter) Value() uint64 { return c.v.Load() }
+
+// Histogram acumula observaciones en buckets con límites superiores fijos,
+// siguiendo la semántica de los histogramas de Prometheus.
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64 // Límites superiores de cada bucket, en orden creciente
+	buckets []uint64  // Observaciones en cada bucket (no acumuladas)
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram crea un histograma con los límites superiores indicados.
+// El bucket +Inf se añade implícitamente.
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{
+		bounds:  bounds,
+		buckets: make([]uint64, len(bounds)),
+	}
+}
+
+// Observe registra una observación.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += v
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.buckets[i]++
+			return
+		}
+	}
+}
+
+// durationBuckets son los límites, en segundos, de los histogramas de duración.
+var durationBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Metrics recoge las métricas del servidor y las expone en el formato de
+// texto de Prometheus. Los contadores e histogramas de trabajos se alimentan
+// observando las transiciones del JobStore; los gauges se leen del
+// Dispatcher en el momento de exponerlos.
+type Metrics struct {
+	JobsAccepted  Counter          // Trabajos aceptados (incluidos los recuperados del WAL)
+	JobsCompleted Counter          // Trabajos terminados correctamente
+	JobsFailed    Counter          // Trabajos fallidos definitivamente
+	JobRetries    Counter          // Intentos fallidos que se reintentarán
+	ScaleUps      Counter          // Veces que el escalado automático añadió workers
+	ScaleDowns    Counter          // Veces que el escalado automático retiró workers
+	QueueWait     *Histogram       // Tiempo en cola hasta que un worker toma el trabajo
+	Execution     *Histogram       // Duración de cada intento en un worker
+	Events        *EventBroker     // Broker de los streams de eventos, o nil
+	Webhooks      *WebhookNotifier // Notificador de los callbacks, o nil
+	RateLimiter   *RateLimiter     // Limitador de solicitudes por cliente, o nil
+
+	dispatcher *Dispatcher
+
+	mu       sync.Mutex
+	enqueued map[string]time.Time // Momento en que cada trabajo entró en la cola
+	started  map[string]time.Time // Momento en que empezó el intento en curso
+}
+
+// NewMetrics crea las métricas del servidor para el dispatcher indicado.
+func NewMetrics(dispatcher *Dispatcher) *Metrics {
+	return &Metrics{
+		QueueWait:  NewHistogram(durationBuckets...),
+		Execution:  NewHistogram(durationBuckets...),
+		dispatcher: dispatcher,
+		enqueued:   make(map[string]time.Time),
+		started:    make(map[string]time.Time),
+	}
+}
+
+// Observe actualiza las métricas con una transición de estado de un trabajo.
+// Está pensada para registrarse con JobStore.Observe.
+func (m *Metrics) Observe(rec JobRecord) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if start, ok := m.started[rec.ID]; ok && rec.Status != StatusRunning {
+		m.Execution.Observe(now.Sub(start).Seconds())
+		delete(m.started, rec.ID)
+	}
+
+	switch rec.Status {
+	case StatusQueued:
+		m.JobsAccepted.Inc()
+		m.enqueued[rec.ID] = rec.CreatedAt
+	case StatusRetrying:
+		m.JobRetries.Inc()
+		if rec.NextRetry != nil {
+			m.enqueued[rec.ID] = *rec.NextRetry
+		}
+	case StatusRunning:
+		if queuedAt, ok := m.enqueued[rec.ID]; ok {
+			m.QueueWait.Observe(max(now.Sub(queuedAt), 0).Seconds())
+		}
+		delete(m.enqueued, rec.ID)
+		m.started[rec.ID] = now
+	default: // Estado final.
+		delete(m.enqueued, rec.ID)
+		switch rec.Status {
+		case StatusDone:
+			m.JobsCompleted.Inc()
+		case StatusFailed:
+			m.JobsFailed.Inc()
+		}
+	}
+}
+
+// ObserveScaling cuenta un cambio en el número de workers del pool.
+// Está pensada para asignarse a Dispatcher.OnScale.
+func (m *Metrics) ObserveScaling(ev ScalingEvent) {
+	if ev.To > ev.From {
+		m.ScaleUps.Inc()
+	} else {
+		m.ScaleDowns.Inc()
+	}
+}
+
+// WriteTo escribe todas las métricas en el formato de texto de Prometheus.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	writeGauge(&b, "fibonacci_queue_depth", "Jobs waiting in the job queue.", float64(m.dispatcher.JobQueue.Len()))
+	writeGauge(&b, "fibonacci_queue_capacity", "Maximum number of jobs in the job queue.", float64(m.dispatcher.JobQueue.Cap()))
+	writeGauge(&b, "fibonacci_queue_backlog_capacity", "Additional jobs from batches admitted by the job queue.", float64(m.dispatcher.JobQueue.Backlog()))
+	writeGauge(&b, "fibonacci_workers", "Workers in the pool.", float64(m.dispatcher.WorkerCount()))
+	writeGauge(&b, "fibonacci_workers_idle", "Workers waiting for a job.", float64(m.dispatcher.IdleWorkers()))
+	writeGauge(&b, "fibonacci_workers_min", "Minimum number of workers in the pool.", float64(m.dispatcher.MinWorkers()))
+	writeGauge(&b, "fibonacci_workers_max", "Maximum number of workers in the pool.", float64(m.dispatcher.MaxWorkers))
+	writeCounter(&b, "fibonacci_jobs_accepted_total", "Jobs accepted by the server.", m.JobsAccepted.Value())
+	writeCounter(&b, "fibonacci_jobs_completed_total", "Jobs that finished successfully.", m.JobsCompleted.Value())
+	writeCounter(&b, "fibonacci_jobs_failed_total", "Jobs that failed after exhausting their retries.", m.JobsFailed.Value())
+	writeCounter(&b, "fibonacci_job_retries_total", "Failed attempts scheduled for retry.", m.JobRetries.Value())
+	if dlq := m.dispatcher.DeadLetters; dlq != nil {
+		writeGauge(&b, "fibonacci_dead_letters", "Jobs in the dead letter queue.", float64(dlq.Len()))
+		writeCounter(&b, "fibonacci_dead_letters_dropped_total", "Dead letters dropped to respect the queue capacity or TTL.", dlq.Dropped.Value())
+	}
+	writeCounter(&b, "fibonacci_jobs_coalesced_total", "Jobs that shared the execution of an identical in-flight job.", m.dispatcher.Coalesced.Value())
+	writeCounter(&b, "fibonacci_scale_ups_total", "Times the autoscaler added workers.", m.ScaleUps.Value())
+	writeCounter(&b, "fibonacci_scale_downs_total", "Times the autoscaler retired workers.", m.ScaleDowns.Value())
+	if cache := m.dispatcher.Cache; cache != nil {
+		writeCounter(&b, "fibonacci_cache_hits_total", "Result cache lookups that found the result.", cache.Hits.Value())
+		writeCounter(&b, "fibonacci_cache_misses_total", "Result cache lookups that did not find the result.", cache.Misses.Value())
+		writeGauge(&b, "fibonacci_cache_entries", "Results stored in the result cache.", float64(cache.Len()))
+		writeGauge(&b, "fibonacci_cache_bytes", "Total size of the results stored in the result cache.", float64(cache.Bytes()))
+	}
+	if events := m.Events; events != nil {
+		writeGauge(&b, "fibonacci_event_subscribers", "Open event streams.", float64(events.Subscribers()))
+		writeCounter(&b, "fibonacci_event_subscribers_dropped_total", "Event streams closed because the client fell behind.", events.Dropped.Value())
+	}
+	if webhooks := m.Webhooks; webhooks != nil {
+		writeCounter(&b, "fibonacci_callbacks_delivered_total", "Job callbacks delivered to their callback_url.", webhooks.Delivered.Value())
+		writeCounter(&b, "fibonacci_callbacks_failed_total", "Job callbacks abandoned after exhausting their attempts.", webhooks.Failed.Value())
+	}
+	if limiter := m.RateLimiter; limiter != nil {
+		writeCounter(&b, "fibonacci_rate_limited_total", "Job requests rejected by the per-client rate limit.", limiter.Limited.Value())
+		writeGauge(&b, "fibonacci_rate_limit_clients", "Clients tracked by the rate limiter.", float64(limiter.Clients()))
+	}
+	writeHistogram(&b, "fibonacci_job_queue_wait_seconds", "Time jobs spend queued before a worker picks them up.", m.QueueWait)
+	writeHistogram(&b, "fibonacci_job_execution_seconds", "Duration of each job attempt in a worker.", m.Execution)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// MetricsHandler maneja GET /metrics exponiendo las métricas en el formato
+// de texto de Prometheus.
+func MetricsHandler(w http.ResponseWriter, r *http.Request, metrics *Metrics) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteTo(w)
+}
+
+// writeHeader escribe las líneas HELP y TYPE de una métrica.
+func writeHeader(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeGauge escribe una métrica de tipo gauge.
+func writeGauge(b *strings.Builder, name, help string, v float64) {
+	writeHeader(b, name, help, "gauge")
+	fmt.Fprintf(b, "%s %s\n", name, formatFloat(v))
+}
+
+// writeCounter escribe una métrica de tipo counter.
+func writeCounter(b *strings.Builder, name, help string, v uint64) {
+	writeHeader(b, name, help, "counter")
+	fmt.Fprintf(b, "%s %d\n", name, v)
+}
+
+// writeHistogram escribe un histograma con sus buckets acumulados, su suma y su cuenta.
+func writeHistogram(b *strings.Builder, name, help string, h *Histogram) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(b, name, help, "histogram")
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.buckets[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
+
+// formatFloat formatea un valor con la representación más corta posible.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,142 @@
+// Este archivo contiene pruebas unitarias para las métricas en formato
+// Prometheus. No necesitan un servidor Prometheus: basta con leer la salida
+// de texto que expone GET /metrics.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistogramObserve verifica que cada observación caiga en el primer
+// bucket cuyo límite superior no supere y que se exponga de forma acumulada.
+func TestHistogramObserve(t *testing.T) {
+	h := NewHistogram(0.1, 1, 10)
+	for _, v := range []float64{0.05, 0.1, 0.5, 5, 50} {
+		h.Observe(v)
+	}
+
+	var b strings.Builder
+	writeHistogram(&b, "test_seconds", "Test histogram.", h)
+	got := b.String()
+
+	testCases := []string{
+		`test_seconds_bucket{le="0.1"} 2`,
+		`test_seconds_bucket{le="1"} 3`,
+		`test_seconds_bucket{le="10"} 4`,
+		`test_seconds_bucket{le="+Inf"} 5`,
+		`test_seconds_sum 55.65`,
+		`test_seconds_count 5`,
+		`# TYPE test_seconds histogram`,
+	}
+	for _, want := range testCases {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("histogram output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+// TestMetricsHandler verifica que GET /metrics refleje las transiciones de
+// estado de los trabajos y el estado de la cola y del pool de workers.
+//
+// El dispatcher no se arranca: las transiciones se simulan directamente
+// sobre el JobStore, que notifica a las métricas como lo haría un worker.
+func TestMetricsHandler(t *testing.T) {
+	store := NewJobStore()
+	queue := NewJobQueue(5, 0, time.Second)
+	dispatcher := NewDispatcher(queue, 2, store, nil)
+	metrics := NewMetrics(dispatcher)
+	store.Observe(metrics.Observe)
+
+	done := NewJob("done", 10, 0, time.Time{}, PriorityNormal)
+	failed := NewJob("failed", 20, 0, time.Time{}, PriorityNormal)
+	queued := NewJob("queued", 30, 0, time.Time{}, PriorityNormal)
+	for _, job := range []Job{done, failed, queued} {
+		store.Add(job)
+	}
+	if err := queue.Push(queued, 0); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	store.MarkRunning(done.ID, 0)
+	store.MarkDone(done.ID, "55")
+	store.MarkRunning(failed.ID, 0)
+	store.MarkRetrying(failed.ID, "boom", time.Now())
+	store.MarkRunning(failed.ID, 0)
+	store.MarkFailed(failed.ID, "boom")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil), metrics)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q; want Prometheus text format", ct)
+	}
+
+	body := rec.Body.String()
+	testCases := []string{
+		"fibonacci_queue_depth 1",
+		"fibonacci_queue_capacity 5",
+		"fibonacci_jobs_accepted_total 3",
+		"fibonacci_jobs_completed_total 1",
+		"fibonacci_jobs_failed_total 1",
+		"fibonacci_job_retries_total 1",
+		"fibonacci_job_queue_wait_seconds_count 3",
+		"fibonacci_job_execution_seconds_count 3",
+	}
+	for _, want := range testCases {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// TestMetricsIgnoreRejectedJobs verifica que los trabajos rechazados por
+// tener la cola llena no cuenten como aceptados.
+func TestMetricsIgnoreRejectedJobs(t *testing.T) {
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+	metrics := NewMetrics(dispatcher)
+	store.Observe(metrics.Observe)
+
+	for i, want := range []int{http.StatusCreated, http.StatusServiceUnavailable, http.StatusServiceUnavailable} {
+		req := httptest.NewRequest(http.MethodPost, "/fibonacci?name=test&value=10&delay=0s", nil)
+		rec := httptest.NewRecorder()
+		RequestHandler(rec, req, dispatcher, store, RequestOptions{MaxValue: 100})
+		if rec.Code != want {
+			t.Fatalf("request %d status = %d; want %d", i, rec.Code, want)
+		}
+	}
+
+	if got := metrics.JobsAccepted.Value(); got != 1 {
+		t.Errorf("JobsAccepted = %d; want 1", got)
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if got := len(metrics.enqueued); got != 1 {
+		t.Errorf("tracked queued jobs = %d; want 1", got)
+	}
+}
+
+// TestMetricsHandlerMethodNotAllowed verifica que solo se acepte GET y que
+// el rechazo use el formato de error JSON de la API.
+func TestMetricsHandlerMethodNotAllowed(t *testing.T) {
+	metrics := NewMetrics(NewDispatcher(NewJobQueue(1, 0, time.Second), 1, NewJobStore(), nil))
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil), metrics)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); allow != http.MethodGet {
+		t.Errorf("Allow = %q; want %q", allow, http.MethodGet)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q; want application/json", ct)
+	}
+}
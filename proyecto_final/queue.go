@@ -0,0 +1,410 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority indica la prioridad de un trabajo dentro del JobQueue.
+type Priority int
+
+const (
+	PriorityLow    Priority = iota // Trabajos por lotes que pueden esperar
+	PriorityNormal                 // Prioridad por defecto
+	PriorityHigh                   // Trabajos interactivos
+)
+
+// String devuelve el nombre de la prioridad: "low", "normal" o "high".
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// MarshalText serializa la prioridad por su nombre, por ejemplo en JSON.
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText interpreta el nombre de una prioridad con ParsePriority.
+func (p *Priority) UnmarshalText(text []byte) error {
+	priority, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// ParsePriority convierte "low", "normal" o "high" en una Priority.
+// Una cadena vacía equivale a PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return 0, fmt.Errorf("unknown priority %q", s)
+}
+
+var (
+	// ErrQueueFull se devuelve cuando el JobQueue sigue lleno tras el tiempo de espera.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrQueueClosed se devuelve al encolar un trabajo en un JobQueue cerrado.
+	ErrQueueClosed = errors.New("job queue is closed")
+)
+
+// JobQueue es una cola de trabajos acotada y ordenada por prioridad.
+//
+// Para evitar que los trabajos de baja prioridad esperen indefinidamente
+// (starvation), cada nivel de prioridad equivale a haber llegado aging antes:
+// un trabajo low que lleva esperando más de 2*aging pasa por delante de un
+// trabajo high recién llegado. Dentro de la misma posición se respeta el
+// orden de llegada.
+//
+// Los lotes pueden ocupar, además de la capacidad de la cola, un número
+// acotado de huecos adicionales (backlog), de modo que un lote atómico mayor
+// que la cola se acepta entero y va entrando a medida que se vacía. Mientras
+// queden trabajos de lotes en el backlog, los huecos que se liberan se
+// devuelven primero al backlog y la cola sigue llena para los trabajos sueltos.
+type JobQueue struct {
+	mu      sync.Mutex
+	items   jobHeap
+	seq     uint64        // Contador para desempatar por orden de llegada
+	aging   time.Duration // Ventaja temporal por cada nivel de prioridad
+	closed  bool
+	pending int        // Huecos reservados que aún no se han ocupado ni devuelto
+	settled *sync.Cond // Notifica a Drain que pending llegó a cero
+
+	slots   chan struct{} // Semáforo con un hueco por cada trabajo en cola
+	backlog chan struct{} // Semáforo de los huecos adicionales de los lotes, nil si no hay
+	ready   chan struct{} // Notifica a Pop que hay trabajos nuevos o que la cola se cerró
+}
+
+// NewJobQueue crea una cola con la capacidad, el backlog de los lotes y el
+// intervalo de envejecimiento indicados.
+//
+// Parámetros:
+//   - capacity: Número máximo de trabajos pendientes
+//   - backlog: Trabajos de lotes que se admiten además de capacity
+//   - aging: Tiempo de espera que compensa un nivel de prioridad
+//
+// Retorna:
+//   - *JobQueue: Nueva cola vacía
+func NewJobQueue(capacity, backlog int, aging time.Duration) *JobQueue {
+	q := &JobQueue{
+		aging: aging,
+		slots: make(chan struct{}, capacity),
+		ready: make(chan struct{}, 1),
+	}
+	if backlog > 0 {
+		q.backlog = make(chan struct{}, backlog)
+	}
+	q.settled = sync.NewCond(&q.mu)
+	return q
+}
+
+// Push añade un trabajo a la cola esperando como máximo wait a que haya
+// espacio. Con wait igual a cero no bloquea.
+//
+// Retorna ErrQueueFull si la cola sigue llena al agotar la espera y
+// ErrQueueClosed si la cola ya está cerrada.
+func (q *JobQueue) Push(job Job, wait time.Duration) error {
+	if err := q.Reserve(1, wait); err != nil {
+		return err
+	}
+	q.PushReserved([]Job{job})
+	return nil
+}
+
+// Reserve reserva huecos para n trabajos, todos o ninguno, esperando como
+// máximo wait a que haya espacio. Los huecos se ocupan con PushReserved y
+// los que no se usen deben devolverse con Release; mientras tanto, Pop y
+// Drain esperan a que se ocupen aunque la cola se cierre.
+//
+// Retorna ErrQueueFull si no hay espacio para todos al agotar la espera
+// (inmediatamente si n supera la capacidad) y ErrQueueClosed si la cola ya
+// está cerrada.
+func (q *JobQueue) Reserve(n int, wait time.Duration) error {
+	return q.reserve(n, wait, nil)
+}
+
+// ReserveBatch es como Reserve, pero los huecos pueden tomarse también del
+// backlog de los lotes: n puede llegar a Cap() + Backlog().
+func (q *JobQueue) ReserveBatch(n int, wait time.Duration) error {
+	return q.reserve(n, wait, q.backlog)
+}
+
+// reserve implementa Reserve y ReserveBatch. Con backlog nil solo se toman
+// huecos de la cola.
+func (q *JobQueue) reserve(n int, wait time.Duration, backlog chan struct{}) error {
+	if q.isClosed() {
+		return ErrQueueClosed
+	}
+	if n > q.Cap()+cap(backlog) {
+		return ErrQueueFull
+	}
+	deadline := time.Now().Add(wait)
+	for i := range n {
+		if err := q.acquire(time.Until(deadline), backlog); err != nil {
+			q.mu.Lock()
+			q.freeSlots(i)
+			q.mu.Unlock()
+			return err
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		q.freeSlots(n)
+		return ErrQueueClosed
+	}
+	q.pending += n
+	return nil
+}
+
+// Release devuelve n huecos reservados con Reserve que no llegaron a usarse.
+func (q *JobQueue) Release(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.freeSlots(n)
+	q.settle(n)
+}
+
+// PushReserved añade a la cola trabajos para los que ya se reservó un hueco
+// con Reserve. Los trabajos se añaden aunque la cola se haya cerrado después
+// de la reserva: Pop y Drain los entregan igualmente.
+func (q *JobQueue) PushReserved(jobs []Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.settle(len(jobs))
+	now := time.Now()
+	for _, job := range jobs {
+		q.seq++
+		heap.Push(&q.items, &queueItem{
+			job:        job,
+			rank:       now.Add(-time.Duration(job.Priority) * q.aging),
+			seq:        q.seq,
+			enqueuedAt: now,
+		})
+	}
+}
+
+// settle descuenta n huecos reservados que ya se ocuparon o devolvieron y
+// despierta a Pop y Drain. Debe llamarse con mu tomado.
+func (q *JobQueue) settle(n int) {
+	q.pending -= n
+	if q.pending == 0 {
+		q.settled.Broadcast()
+	}
+	q.notify()
+}
+
+// notify despierta a Pop si estaba esperando.
+func (q *JobQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default: // Ya hay una notificación pendiente.
+	}
+}
+
+// freeSlots devuelve n huecos ocupados o reservados. Los huecos son
+// intercambiables, así que se devuelven primero los del backlog. Debe
+// llamarse con mu tomado.
+func (q *JobQueue) freeSlots(n int) {
+	for range n {
+		select {
+		case <-q.backlog:
+		default:
+			<-q.slots
+		}
+	}
+}
+
+// acquire reserva un hueco en la cola, o en backlog si no es nil, esperando
+// como máximo wait.
+func (q *JobQueue) acquire(wait time.Duration, backlog chan struct{}) error {
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case backlog <- struct{}{}:
+		return nil
+	default:
+		if wait <= 0 {
+			return ErrQueueFull
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		return nil
+	case backlog <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrQueueFull
+	}
+}
+
+// Pop extrae el trabajo con mayor prioridad efectiva. Bloquea hasta que haya
+// un trabajo disponible, hasta que la cola se cierre y quede vacía, sin
+// huecos reservados pendientes de ocupar, o hasta que se cierre quit; en los
+// dos últimos casos el segundo valor es false.
+func (q *JobQueue) Pop(quit <-chan struct{}) (Job, bool) {
+	for {
+		q.mu.Lock()
+		if q.items.Len() > 0 {
+			item := heap.Pop(&q.items).(*queueItem)
+			q.freeSlots(1)
+			q.mu.Unlock()
+			return item.job, true
+		}
+		finished := q.closed && q.pending == 0
+		q.mu.Unlock()
+
+		if finished {
+			q.notify() // Despierta a cualquier otro Pop en espera.
+			return Job{}, false
+		}
+		select {
+		case <-q.ready:
+		case <-quit:
+			return Job{}, false
+		}
+	}
+}
+
+// Remove retira de la cola el trabajo con el ID indicado y libera su hueco.
+// Retorna false si el trabajo no está en la cola, por ejemplo porque ya se
+// entregó a un worker.
+func (q *JobQueue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item.job.ID == id {
+			heap.Remove(&q.items, i)
+			q.freeSlots(1)
+			return true
+		}
+	}
+	return false
+}
+
+// Len devuelve el número de trabajos pendientes en la cola.
+func (q *JobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// OldestWait devuelve cuánto lleva esperando el trabajo más antiguo de la
+// cola, o cero si está vacía.
+func (q *JobQueue) OldestWait() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var oldest time.Time
+	for _, item := range q.items {
+		if oldest.IsZero() || item.enqueuedAt.Before(oldest) {
+			oldest = item.enqueuedAt
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+// Cap devuelve el número máximo de trabajos pendientes que admite la cola
+// para los trabajos sueltos.
+func (q *JobQueue) Cap() int {
+	return cap(q.slots)
+}
+
+// Backlog devuelve el número de trabajos de lotes que la cola admite además
+// de Cap().
+func (q *JobQueue) Backlog() int {
+	return cap(q.backlog)
+}
+
+// Close impide añadir trabajos nuevos. Los trabajos pendientes pueden
+// seguir extrayéndose con Pop o Drain.
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.notify()
+}
+
+// Drain vacía la cola y devuelve los trabajos pendientes en orden de
+// prioridad, después de esperar a que se ocupen o devuelvan los huecos
+// reservados.
+func (q *JobQueue) Drain() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.pending > 0 {
+		q.settled.Wait()
+	}
+	jobs := make([]Job, 0, q.items.Len())
+	for q.items.Len() > 0 {
+		jobs = append(jobs, heap.Pop(&q.items).(*queueItem).job)
+		q.freeSlots(1)
+	}
+	return jobs
+}
+
+// isClosed indica si la cola está cerrada.
+func (q *JobQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// queueItem es un trabajo dentro del heap junto a su posición efectiva.
+type queueItem struct {
+	job        Job
+	rank       time.Time // Llegada ajustada por prioridad; menor significa antes
+	seq        uint64
+	enqueuedAt time.Time // Llegada real a la cola
+}
+
+// jobHeap implementa heap.Interface ordenando por rank y luego por llegada.
+type jobHeap []*queueItem
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if !h[i].rank.Equal(h[j].rank) {
+		return h[i].rank.Before(h[j].rank)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x any) { *h = append(*h, x.(*queueItem)) }
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
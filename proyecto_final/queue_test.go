@@ -0,0 +1,164 @@
+// Este archivo contiene pruebas unitarias para el JobQueue: el orden por
+// prioridad, la reserva de huecos y el cierre de la cola. Los trabajos se
+// crean con NewJob y se extraen directamente con Pop o Drain, sin
+// dispatcher.
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJobQueuePriority verifica que Pop entregue primero los trabajos de
+// mayor prioridad, en orden de llegada dentro de cada nivel, y que un
+// trabajo que espera lo suficiente adelante a los de mayor prioridad.
+func TestJobQueuePriority(t *testing.T) {
+	type push struct {
+		name     string
+		priority Priority
+		after    time.Duration // Espera antes de encolarlo
+	}
+	testCases := []struct {
+		name   string
+		aging  time.Duration
+		pushes []push
+		want   string
+	}{
+		{
+			name:  "by priority",
+			aging: time.Hour,
+			pushes: []push{
+				{name: "low", priority: PriorityLow},
+				{name: "normal1", priority: PriorityNormal},
+				{name: "high", priority: PriorityHigh},
+				{name: "normal2", priority: PriorityNormal},
+			},
+			want: "high,normal1,normal2,low",
+		},
+		{
+			name:  "aging",
+			aging: 10 * time.Millisecond,
+			pushes: []push{
+				{name: "low", priority: PriorityLow},
+				{name: "high", priority: PriorityHigh, after: 30 * time.Millisecond},
+				{name: "normal", priority: PriorityNormal},
+			},
+			want: "low,high,normal",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewJobQueue(len(tc.pushes), 0, tc.aging)
+			for _, p := range tc.pushes {
+				time.Sleep(p.after)
+				if err := q.Push(NewJob(p.name, 10, 0, time.Time{}, p.priority), 0); err != nil {
+					t.Fatalf("Push(%s) error = %v", p.name, err)
+				}
+			}
+			var order []string
+			for range tc.pushes {
+				job, _ := q.Pop(nil)
+				order = append(order, job.Name)
+			}
+			if got := strings.Join(order, ","); got != tc.want {
+				t.Errorf("Pop() order = %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestJobQueueReserveAcrossClose verifica que los trabajos con hueco
+// reservado antes del cierre se sigan entregando, y que tras el cierre no
+// se puedan reservar huecos nuevos.
+func TestJobQueueReserveAcrossClose(t *testing.T) {
+	testCases := []struct {
+		name    string
+		collect func(q *JobQueue) []Job
+	}{
+		{
+			name: "pop",
+			collect: func(q *JobQueue) []Job {
+				var jobs []Job
+				for {
+					job, ok := q.Pop(nil)
+					if !ok {
+						return jobs
+					}
+					jobs = append(jobs, job)
+				}
+			},
+		},
+		{name: "drain", collect: func(q *JobQueue) []Job { return q.Drain() }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewJobQueue(2, 0, time.Second)
+			if err := q.Reserve(2, 0); err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			q.Close()
+			if err := q.Reserve(1, 0); !errors.Is(err, ErrQueueClosed) {
+				t.Errorf("Reserve() after Close error = %v; want %v", err, ErrQueueClosed)
+			}
+
+			job := NewJob("reserved", 10, 0, time.Time{}, PriorityNormal)
+			go func() {
+				time.Sleep(10 * time.Millisecond) // Pop y Drain deben esperar a la reserva.
+				q.Release(1)
+				q.PushReserved([]Job{job})
+			}()
+			jobs := tc.collect(q)
+			if len(jobs) != 1 || jobs[0].ID != job.ID {
+				t.Errorf("collected %d jobs; want only the reserved one", len(jobs))
+			}
+		})
+	}
+}
+
+// TestJobQueueBacklog verifica que los lotes puedan ocupar el backlog además
+// de la capacidad de la cola, y que los huecos liberados vuelvan antes al
+// backlog que a los trabajos sueltos.
+func TestJobQueueBacklog(t *testing.T) {
+	q := NewJobQueue(1, 2, time.Second)
+	push := func(name string) error {
+		return q.Push(NewJob(name, 10, 0, time.Time{}, PriorityNormal), 0)
+	}
+	pushBatch := func(n int) error {
+		if err := q.ReserveBatch(n, 0); err != nil {
+			return err
+		}
+		jobs := make([]Job, n)
+		for i := range jobs {
+			jobs[i] = NewJob("batch", 10, 0, time.Time{}, PriorityNormal)
+		}
+		q.PushReserved(jobs)
+		return nil
+	}
+
+	steps := []struct {
+		name    string
+		run     func() error
+		wantErr error
+	}{
+		{name: "single job", run: func() error { return push("first") }},
+		{name: "single job on a full queue", run: func() error { return push("second") }, wantErr: ErrQueueFull},
+		{name: "batch into backlog", run: func() error { return pushBatch(2) }},
+		{name: "batch on a full backlog", run: func() error { return pushBatch(1) }, wantErr: ErrQueueFull},
+		{name: "pop", run: func() error { q.Pop(nil); return nil }},
+		{name: "single job while backlog drains", run: func() error { return push("third") }, wantErr: ErrQueueFull},
+		{name: "pop twice", run: func() error { q.Pop(nil); q.Pop(nil); return nil }},
+		{name: "single job after backlog drained", run: func() error { return push("fourth") }},
+		{name: "batch larger than queue and backlog", run: func() error { return pushBatch(4) }, wantErr: ErrQueueFull},
+	}
+	for _, step := range steps {
+		if err := step.run(); !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v; want %v", step.name, err, step.wantErr)
+		}
+	}
+	if got := q.Len(); got != 1 {
+		t.Errorf("Len() = %d; want 1", got)
+	}
+}
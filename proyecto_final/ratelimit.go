@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit es el ritmo de solicitudes que se permite a un cliente: Rate
+// solicitudes por segundo de media, con ráfagas de hasta Burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateDecision es el resultado de consultar el limitador para una solicitud.
+type RateDecision struct {
+	Allowed    bool
+	Limit      int           // Tamaño de la ráfaga del cliente
+	Remaining  int           // Solicitudes que aún puede hacer de inmediato
+	Reset      time.Duration // Tiempo hasta recuperar la ráfaga completa
+	RetryAfter time.Duration // Tiempo hasta la próxima solicitud permitida, si se rechazó
+}
+
+// RateLimiter limita las solicitudes de cada cliente con un token bucket:
+// cada solicitud consume un token y los tokens se recuperan a razón de Rate
+// por segundo hasta Burst. Los clientes que pasan un tiempo sin hacer
+// solicitudes se olvidan para no acumular estado. Es seguro para uso
+// concurrente.
+type RateLimiter struct {
+	Limited Counter // Solicitudes rechazadas
+
+	limit     RateLimit            // Límite de los clientes sin uno propio
+	overrides map[string]RateLimit // Límites propios por clave de cliente
+	idleTTL   time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time // Reloj, sustituible en las pruebas
+}
+
+// tokenBucket es el estado de un cliente.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time // Última vez que se recargaron los tokens
+}
+
+// NewRateLimiter crea un limitador sin clientes.
+//
+// Parámetros:
+//   - limit: Límite de los clientes sin uno propio
+//   - overrides: Límites propios por clave de cliente (ver clientKey), o nil
+//   - idleTTL: Tiempo sin solicitudes tras el que se olvida a un cliente
+//
+// Retorna:
+//   - *RateLimiter: Nuevo limitador
+func NewRateLimiter(limit RateLimit, overrides map[string]RateLimit, idleTTL time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:     limit,
+		overrides: overrides,
+		idleTTL:   idleTTL,
+		clients:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+// Allow consume un token del cliente key si le queda alguno.
+func (l *RateLimiter) Allow(key string) RateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.clients[key]
+	if !ok {
+		limit, ok := l.overrides[key]
+		if !ok {
+			limit = l.limit
+		}
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.clients[key] = bucket
+	}
+	rate, burst := bucket.limit.Rate, float64(bucket.limit.Burst)
+	bucket.tokens = min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	decision := RateDecision{Limit: bucket.limit.Burst}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsDuration((1 - bucket.tokens) / rate)
+		l.Limited.Inc()
+	}
+	decision.Remaining = int(bucket.tokens)
+	decision.Reset = secondsDuration((burst - bucket.tokens) / rate)
+	return decision
+}
+
+// Clients devuelve el número de clientes de los que se guarda estado.
+func (l *RateLimiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.clients)
+}
+
+// sweep olvida a los clientes que llevan más de idleTTL sin solicitudes. Se
+// ejecuta como mucho una vez cada idleTTL. Debe llamarse con mu tomado.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.clients {
+		if now.Sub(bucket.last) >= l.idleTTL {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Handler devuelve next precedido por el limitador: las solicitudes de un
+// cliente que superan su límite se responden con 429 sin llegar a next.
+// Todas las respuestas llevan las cabeceras RateLimit-Limit,
+// RateLimit-Remaining y RateLimit-Reset, y las rechazadas también
+// Retry-After.
+func (l *RateLimiter) Handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision := l.Allow(clientKey(r))
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		if !decision.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(decision.RetryAfter), 1)))
+			writeError(w, http.StatusTooManyRequests, "rate_limited", "Too many requests, retry later", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientKey identifica al cliente de una solicitud: "key:" seguido del
+// nombre de su identidad si está autenticado, o "ip:" seguido de su
+// dirección. Sin autenticación la cabecera X-API-Key no se tiene en cuenta:
+// cualquiera podría inventar una clave nueva en cada solicitud para
+// saltarse el límite.
+func clientKey(r *http.Request) string {
+	if id, ok := CallerFrom(r.Context()); ok {
+		return "key:" + id.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ParseRateLimits lee los límites propios de los clientes con el formato
+// "clave=rate:burst", separados por comas, donde clave es como las de
+// clientKey. Ej: "key:alice=20:40,ip:10.0.0.7=1:2".
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	if s == "" {
+		return limits, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		key, value, ok := cutLast(strings.TrimSpace(entry), "=")
+		rateText, burstText, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 || key == "" {
+			return nil, fmt.Errorf("rate limit %q: want key=rate:burst", entry)
+		}
+		rate, err := strconv.ParseFloat(rateText, 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("rate limit %q: rate must be a positive number", entry)
+		}
+		burst, err := strconv.Atoi(burstText)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("rate limit %q: burst must be at least 1", entry)
+		}
+		limits[key] = RateLimit{Rate: rate, Burst: burst}
+	}
+	return limits, nil
+}
+
+// cutLast divide s por la última aparición de sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// secondsDuration convierte segundos en una duración.
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ceilSeconds redondea d hacia arriba a segundos enteros, como se expresan
+// en las cabeceras.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,152 @@
+// Este archivo contiene pruebas unitarias para el limitador de solicitudes
+// por cliente. El reloj del limitador se sustituye para controlar la
+// recarga de los tokens sin esperas reales.
+
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRateLimiterAllow verifica el consumo y la recarga de los tokens, los
+// límites propios de cada cliente y el olvido de los clientes inactivos.
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewRateLimiter(RateLimit{Rate: 2, Burst: 3}, map[string]RateLimit{"key:vip": {Rate: 10, Burst: 5}}, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	testCases := []struct {
+		name          string
+		advance       time.Duration
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+		wantLimit     int
+	}{
+		{name: "first request", key: "ip:a", wantAllowed: true, wantRemaining: 2, wantLimit: 3},
+		{name: "second request", key: "ip:a", wantAllowed: true, wantRemaining: 1, wantLimit: 3},
+		{name: "burst exhausted", key: "ip:a", wantAllowed: true, wantRemaining: 0, wantLimit: 3},
+		{name: "rejected", key: "ip:a", wantAllowed: false, wantRemaining: 0, wantLimit: 3},
+		{name: "other client", key: "ip:b", wantAllowed: true, wantRemaining: 2, wantLimit: 3},
+		{name: "override", key: "key:vip", wantAllowed: true, wantRemaining: 4, wantLimit: 5},
+		{name: "refilled", advance: 500 * time.Millisecond, key: "ip:a", wantAllowed: true, wantRemaining: 0, wantLimit: 3},
+		{name: "capped at burst", advance: time.Hour, key: "ip:a", wantAllowed: true, wantRemaining: 2, wantLimit: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+			got := limiter.Allow(tc.key)
+			if got.Allowed != tc.wantAllowed || got.Remaining != tc.wantRemaining || got.Limit != tc.wantLimit {
+				t.Errorf("Allow(%q) = %+v; want allowed %v, remaining %d, limit %d", tc.key, got, tc.wantAllowed, tc.wantRemaining, tc.wantLimit)
+			}
+			if !got.Allowed && got.RetryAfter != 500*time.Millisecond {
+				t.Errorf("RetryAfter = %v; want 500ms", got.RetryAfter)
+			}
+		})
+	}
+
+	// Tras una hora sin solicitudes, b y vip se olvidan; a acaba de usarse.
+	if got := limiter.Clients(); got != 1 {
+		t.Errorf("Clients() = %d; want 1 after the idle clients expire", got)
+	}
+	if got := limiter.Limited.Value(); got != 1 {
+		t.Errorf("Limited = %d; want 1", got)
+	}
+}
+
+// TestRateLimiterHandler verifica las cabeceras y la respuesta 429 del
+// limitador, y que cada identidad autenticada tenga su propio límite.
+func TestRateLimiterHandler(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 0.5, Burst: 1}, nil, time.Minute)
+	handler := limiter.Handler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	testCases := []struct {
+		name        string
+		apiKey      string // Cabecera X-API-Key sin autenticación
+		caller      string // Identidad autenticada
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "allowed",
+			wantStatus:  http.StatusCreated,
+			wantHeaders: map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "2"},
+		},
+		{
+			name:        "limited",
+			wantStatus:  http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "Retry-After": "2"},
+		},
+		{
+			name:        "unauthenticated api key shares the ip bucket",
+			apiKey:      "made-up",
+			wantStatus:  http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0"},
+		},
+		{
+			name:        "identity has its own bucket",
+			caller:      "alice",
+			wantStatus:  http.StatusCreated,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fibonacci", nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			if tc.caller != "" {
+				req = req.WithContext(context.WithValue(req.Context(), identityKey{}, Identity{Name: tc.caller}))
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d; want %d", rec.Code, tc.wantStatus)
+			}
+			for name, want := range tc.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q; want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestParseRateLimits verifica el formato de los límites propios.
+func TestParseRateLimits(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{input: "", want: map[string]RateLimit{}},
+		{
+			input: "key:abc=20:40, ip:10.0.0.7=0.5:1",
+			want:  map[string]RateLimit{"key:abc": {Rate: 20, Burst: 40}, "ip:10.0.0.7": {Rate: 0.5, Burst: 1}},
+		},
+		{input: "ip:::1=1:1", want: map[string]RateLimit{"ip:::1": {Rate: 1, Burst: 1}}},
+		{input: "key:abc=20", wantErr: true},
+		{input: "key:abc=0:1", wantErr: true},
+		{input: "key:abc=1:0", wantErr: true},
+		{input: "=1:1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseRateLimits(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRateLimits(%q) error = %v; want error %v", tc.input, err, tc.wantErr)
+			}
+			if !tc.wantErr && !maps.Equal(got, tc.want) {
+				t.Errorf("ParseRateLimits(%q) = %v; want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// JobRequest es la solicitud de un trabajo, tal como llega en un cuerpo JSON
+// o en parámetros de formulario. Las duraciones se expresan como en
+// time.ParseDuration (ej: "2s", "500ms").
+type JobRequest struct {
+	Name           string `json:"name"`
+	Value          *int   `json:"value"`
+	Delay          string `json:"delay"`
+	Priority       string `json:"priority,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+	Deadline       string `json:"deadline,omitempty"`
+	MaxAttempts    *int   `json:"max_attempts,omitempty"`
+	Backoff        string `json:"backoff,omitempty"`
+	AttemptTimeout string `json:"attempt_timeout,omitempty"`
+	Wait           string `json:"wait,omitempty"`
+	CallbackURL    string `json:"callback_url,omitempty"`
+}
+
+// FieldErrors asocia a cada campo inválido de una solicitud su mensaje de error.
+type FieldErrors map[string]string
+
+// Add anota el error de un campo, conservando el primero si ya tenía uno.
+func (e FieldErrors) Add(field, message string) {
+	if _, ok := e[field]; !ok {
+		e[field] = message
+	}
+}
+
+// APIError es el cuerpo de las respuestas de error de la API.
+type APIError struct {
+	Code    string      `json:"code"`             // Identificador estable del error
+	Message string      `json:"message"`          // Descripción legible
+	Fields  FieldErrors `json:"fields,omitempty"` // Errores de validación por campo
+	Items   []BatchItem `json:"items,omitempty"`  // Errores de cada elemento de un lote
+}
+
+// writeError responde con un objeto {"error": APIError} y el código indicado.
+func writeError(w http.ResponseWriter, status int, code, message string, fields FieldErrors) {
+	writeJSON(w, status, struct {
+		Error APIError `json:"error"`
+	}{APIError{Code: code, Message: message, Fields: fields}})
+}
+
+// writeMethodNotAllowed responde 405 indicando en Allow los métodos admitidos.
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+}
+
+// isJSON indica si el cuerpo de la solicitud es JSON según su Content-Type.
+func isJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// decodeJobRequest lee la solicitud de un trabajo del cuerpo JSON si el
+// Content-Type es application/json, o de los parámetros de formulario en
+// caso contrario. En ambos casos wait puede indicarse en la URL.
+//
+// Los campos con un tipo incorrecto se anotan en los errores por campo; un
+// cuerpo JSON mal formado se devuelve como error. El cuerpo se limita a
+// maxJobRequestBytes, como cada elemento de un lote: si lo supera se devuelve
+// un *http.MaxBytesError.
+func decodeJobRequest(w http.ResponseWriter, r *http.Request) (JobRequest, FieldErrors, error) {
+	var req JobRequest
+	errs := FieldErrors{}
+	r.Body = http.MaxBytesReader(w, r.Body, maxJobRequestBytes)
+	if !isJSON(r) {
+		var tooLarge *http.MaxBytesError
+		if err := r.ParseForm(); errors.As(err, &tooLarge) {
+			return req, errs, err
+		}
+		req = JobRequest{
+			Name:           r.FormValue("name"),
+			Delay:          r.FormValue("delay"),
+			Priority:       r.FormValue("priority"),
+			Timeout:        r.FormValue("timeout"),
+			Deadline:       r.FormValue("deadline"),
+			Backoff:        r.FormValue("backoff"),
+			AttemptTimeout: r.FormValue("attempt_timeout"),
+			Wait:           r.FormValue("wait"),
+			CallbackURL:    r.FormValue("callback_url"),
+		}
+		req.Value = formInt(r, "value", errs)
+		req.MaxAttempts = formInt(r, "max_attempts", errs)
+		return req, errs, nil
+	}
+
+	req, errs, err := decodeJobJSON(r.Body)
+	if req.Wait == "" {
+		req.Wait = r.URL.Query().Get("wait")
+	}
+	return req, errs, err
+}
+
+// maxJobRequestBytes limita el cuerpo de la solicitud de un trabajo y, de
+// media, el de cada elemento de un lote.
+const maxJobRequestBytes = 1 << 10
+
+// maxNameLength es la longitud máxima, en caracteres, del nombre de un trabajo.
+const maxNameLength = 128
+
+// decodeJobJSON lee la solicitud de un trabajo de un objeto JSON, sin admitir
+// campos desconocidos. Los errores de lectura por exceso de tamaño se
+// devuelven sin modificar.
+func decodeJobJSON(body io.Reader) (JobRequest, FieldErrors, error) {
+	var req JobRequest
+	errs := FieldErrors{}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return req, errs, err
+		}
+		if !errors.As(err, &typeErr) || typeErr.Field == "" {
+			return req, errs, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+		}
+		errs.Add(typeErr.Field, "must be "+jsonKind(typeErr.Type))
+	}
+	return req, errs, nil
+}
+
+// formInt interpreta un parámetro entero opcional del formulario.
+func formInt(r *http.Request, field string, errs FieldErrors) *int {
+	v := r.FormValue(field)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		errs.Add(field, "must be an integer")
+		return nil
+	}
+	return &n
+}
+
+// jsonKind describe el tipo JSON esperado para un tipo de Go.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	}
+	return "a " + t.String()
+}
+
+// Job valida la solicitud y construye el trabajo correspondiente, junto con
+// el tiempo que el cliente quiere esperar su resultado. Los errores de
+// validación se anotan en errs por campo; si hay alguno, el trabajo no se
+// construye y el tercer valor es false.
+func (req JobRequest) Job(opts RequestOptions, defaults RetryPolicy, errs FieldErrors) (Job, time.Duration, bool) {
+	if req.Name == "" {
+		errs.Add("name", "is required")
+	} else if utf8.RuneCountInString(req.Name) > maxNameLength {
+		errs.Add("name", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+
+	if req.Value == nil {
+		errs.Add("value", "is required")
+	} else if *req.Value < 0 {
+		errs.Add("value", "must not be negative")
+	} else if *req.Value > opts.MaxValue {
+		errs.Add("value", fmt.Sprintf("must be at most %d", opts.MaxValue))
+	}
+
+	delay, err := time.ParseDuration(req.Delay)
+	if err != nil || delay < 0 {
+		errs.Add("delay", `must be a duration like "2s"`)
+	}
+
+	priority, err := ParsePriority(req.Priority)
+	if err != nil {
+		errs.Add("priority", "must be high, normal or low")
+	}
+
+	var deadline time.Time
+	switch {
+	case req.Timeout != "" && req.Deadline != "":
+		errs.Add("timeout", "cannot be combined with deadline")
+	case req.Timeout != "":
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			errs.Add("timeout", `must be a positive duration like "10s"`)
+		}
+		deadline = time.Now().Add(d)
+	case req.Deadline != "":
+		deadline, err = time.Parse(time.RFC3339, req.Deadline)
+		if err != nil {
+			errs.Add("deadline", "must be an RFC 3339 time")
+		}
+	}
+
+	retry := parseRetryPolicy(req, defaults, errs)
+
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		switch {
+		case !opts.Callbacks:
+			errs.Add("callback_url", "is not enabled on this server")
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			errs.Add("callback_url", "must be an absolute http or https URL")
+		}
+	}
+
+	var wait time.Duration
+	if req.Wait != "" {
+		wait, err = time.ParseDuration(req.Wait)
+		if err != nil || wait < 0 {
+			errs.Add("wait", `must be a duration like "5s"`)
+		}
+		wait = min(wait, maxWait)
+	}
+
+	if len(errs) > 0 {
+		return Job{}, 0, false
+	}
+	job := NewJob(req.Name, *req.Value, delay, deadline, priority)
+	job.Retry = retry
+	job.CallbackURL = req.CallbackURL
+	return job, wait, true
+}
@@ -0,0 +1,384 @@
+// Este archivo contiene pruebas unitarias para la creación de trabajos con
+// cuerpos JSON y de formulario, y para el formato de los errores de la API.
+// El dispatcher no se arranca: los trabajos aceptados quedan en la cola.
+
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRequestHandlerBodies verifica que la misma solicitud se acepte como
+// JSON y como formulario, y que los errores indiquen cada campo inválido.
+func TestRequestHandlerBodies(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantFields  FieldErrors
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"name": "test", "value": 10, "delay": "0s", "priority": "high"}`,
+			wantStatus:  http.StatusCreated,
+		},
+		{
+			name:        "json with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name": "test", "value": 10, "delay": "0s"}`,
+			wantStatus:  http.StatusCreated,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=test&value=10&delay=0s&priority=high",
+			wantStatus:  http.StatusCreated,
+		},
+		{
+			name:        "json invalid fields",
+			contentType: "application/json",
+			body:        `{"value": 600000, "delay": "soon", "priority": "urgent", "max_attempts": 0}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields: FieldErrors{
+				"name":         "is required",
+				"value":        "must be at most 500000",
+				"delay":        `must be a duration like "2s"`,
+				"priority":     "must be high, normal or low",
+				"max_attempts": "must be at least 1",
+			},
+		},
+		{
+			name:        "json wrong type",
+			contentType: "application/json",
+			body:        `{"name": "test", "value": "ten", "delay": "0s"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields:  FieldErrors{"value": "must be an integer"},
+		},
+		{
+			name:        "json negative value",
+			contentType: "application/json",
+			body:        `{"name": "test", "value": -5, "delay": "0s"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields:  FieldErrors{"value": "must not be negative"},
+		},
+		{
+			name:        "form invalid fields",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=test&value=ten&delay=0s&timeout=10s&deadline=2030-01-01T00:00:00Z",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields: FieldErrors{
+				"value":   "must be an integer",
+				"timeout": "cannot be combined with deadline",
+			},
+		},
+		{
+			name:        "callbacks disabled",
+			contentType: "application/json",
+			body:        `{"name": "test", "value": 10, "delay": "0s", "callback_url": "http://example.com/hook"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields:  FieldErrors{"callback_url": "is not enabled on this server"},
+		},
+		{
+			name:        "json unknown field",
+			contentType: "application/json",
+			body:        `{"name": "test", "value": 10, "delay": "0s", "colour": "red"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_json",
+		},
+		{
+			name:        "json malformed",
+			contentType: "application/json",
+			body:        `{"name": "test",`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_json",
+		},
+		{
+			name:        "json name too long",
+			contentType: "application/json",
+			body:        `{"name": "` + strings.Repeat("a", maxNameLength+1) + `", "value": 10, "delay": "0s"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_request",
+			wantFields:  FieldErrors{"name": "must be at most 128 characters"},
+		},
+		{
+			name:        "json too large",
+			contentType: "application/json",
+			body:        `{"name": "` + strings.Repeat("a", maxJobRequestBytes) + `", "value": 10, "delay": "0s"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "request_too_large",
+		},
+		{
+			name:        "form too large",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=" + strings.Repeat("a", maxJobRequestBytes) + "&value=10&delay=0s",
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "request_too_large",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			dispatcher := NewDispatcher(NewJobQueue(5, 0, time.Second), 1, store, nil)
+			opts := RequestOptions{MaxValue: 500000}
+
+			req := httptest.NewRequest(http.MethodPost, "/fibonacci", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			RequestHandler(rec, req, dispatcher, store, opts)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q; want application/json", ct)
+			}
+
+			if tc.wantCode == "" {
+				var job JobRecord
+				if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+					t.Fatalf("decode job: %v", err)
+				}
+				if job.ID == "" || job.Name != "test" || job.Number != 10 || job.Status != StatusQueued {
+					t.Errorf("job = %+v; want queued job test with number 10", job)
+				}
+				if loc := rec.Header().Get("Location"); loc != "/fibonacci/"+job.ID {
+					t.Errorf("Location = %q; want /fibonacci/%s", loc, job.ID)
+				}
+				return
+			}
+
+			var body struct {
+				Error APIError `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if body.Error.Code != tc.wantCode {
+				t.Errorf("error code = %q; want %q", body.Error.Code, tc.wantCode)
+			}
+			if tc.wantFields != nil && !maps.Equal(body.Error.Fields, tc.wantFields) {
+				t.Errorf("error fields = %v; want %v", body.Error.Fields, tc.wantFields)
+			}
+			if got := dispatcher.JobQueue.Len(); got != 0 {
+				t.Errorf("JobQueue.Len() = %d; want 0", got)
+			}
+		})
+	}
+}
+
+// TestRequestHandlerMethodNotAllowed verifica que los métodos no admitidos
+// respondan 405 con un error JSON y la cabecera Allow.
+func TestRequestHandlerMethodNotAllowed(t *testing.T) {
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+	rec := httptest.NewRecorder()
+	RequestHandler(rec, httptest.NewRequest(http.MethodGet, "/fibonacci", nil), dispatcher, store, RequestOptions{})
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("Allow = %q; want %q", allow, http.MethodPost)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"method_not_allowed"`) {
+		t.Errorf("body = %s; want method_not_allowed error", rec.Body)
+	}
+}
+
+// TestJobHandlerCancel verifica que DELETE cancele los trabajos en cola y en
+// ejecución, liberando el hueco de los que esperaban en la cola, y que
+// responda 409 si el trabajo ya terminó.
+func TestJobHandlerCancel(t *testing.T) {
+	testCases := []struct {
+		name       string
+		prepare    func(d *Dispatcher, id string)
+		wantStatus int
+	}{
+		{name: "queued", prepare: func(d *Dispatcher, id string) {}, wantStatus: http.StatusOK},
+		{
+			name: "running",
+			prepare: func(d *Dispatcher, id string) {
+				d.JobQueue.Pop(nil)
+				d.Store.MarkRunning(id, 1)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "finished",
+			prepare: func(d *Dispatcher, id string) {
+				d.JobQueue.Pop(nil)
+				d.Store.MarkDone(id, "55")
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+			job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+			if err := dispatcher.Enqueue(job, 0); err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+			tc.prepare(dispatcher, job.ID)
+
+			rec := httptest.NewRecorder()
+			JobHandler(rec, httptest.NewRequest(http.MethodDelete, "/fibonacci/"+job.ID, nil), store, dispatcher.Logger)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if got := dispatcher.JobQueue.Len(); got != 0 {
+				t.Errorf("JobQueue.Len() = %d; want 0", got)
+			}
+			// La cola tiene un solo hueco, que debe haber quedado libre.
+			if err := dispatcher.Enqueue(NewJob("next", 11, 0, time.Time{}, PriorityNormal), 0); err != nil {
+				t.Errorf("Enqueue() after DELETE error = %v; want nil", err)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if got, _ := store.Get(job.ID); got.Status != StatusCancelled {
+				t.Errorf("status = %q; want %q", got.Status, StatusCancelled)
+			}
+			if job.Context().Err() == nil {
+				t.Error("job context not cancelled")
+			}
+		})
+	}
+}
+
+// TestJobExpiresWhileQueued verifica que los trabajos que superan su fecha
+// límite en la cola se marquen como timed_out y liberen su hueco.
+func TestJobExpiresWhileQueued(t *testing.T) {
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(2, 0, time.Second), 1, store, nil)
+	deadline := time.Now().Add(20 * time.Millisecond)
+	jobs := []Job{
+		NewJob("first", 10, 0, deadline, PriorityNormal),
+		NewJob("second", 11, 0, deadline, PriorityLow),
+	}
+	for _, job := range jobs {
+		if err := dispatcher.Enqueue(job, 0); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	for _, job := range jobs {
+		done, _ := store.Done(job.ID)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("job %s did not expire", job.Name)
+		}
+		if rec, _ := store.Get(job.ID); rec.Status != StatusTimedOut {
+			t.Errorf("job %s status = %q; want %q", job.Name, rec.Status, StatusTimedOut)
+		}
+	}
+	wait := time.Now().Add(time.Second)
+	for dispatcher.JobQueue.Len() > 0 && time.Now().Before(wait) {
+		time.Sleep(time.Millisecond) // Los observadores se llaman tras cerrar Done.
+	}
+	if got := dispatcher.JobQueue.Len(); got != 0 {
+		t.Errorf("JobQueue.Len() = %d; want 0", got)
+	}
+	if err := dispatcher.Enqueue(NewJob("next", 12, 0, time.Time{}, PriorityNormal), 0); err != nil {
+		t.Errorf("Enqueue() after expiry error = %v; want nil", err)
+	}
+}
+
+// TestRequestHandlerWait verifica que con wait se responda 200 con el
+// resultado si el trabajo termina a tiempo y 202 con el registro si no.
+func TestRequestHandlerWait(t *testing.T) {
+	testCases := []struct {
+		name       string
+		target     string
+		finish     bool // Un worker simulado termina el trabajo
+		wantStatus int
+		wantState  JobStatus
+	}{
+		{name: "without wait", target: "/fibonacci", wantStatus: http.StatusCreated, wantState: StatusQueued},
+		{name: "finishes in time", target: "/fibonacci?wait=5s", finish: true, wantStatus: http.StatusOK, wantState: StatusDone},
+		{name: "still pending", target: "/fibonacci?wait=20ms", wantStatus: http.StatusAccepted, wantState: StatusQueued},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+			if tc.finish {
+				go func() {
+					job, ok := dispatcher.JobQueue.Pop(nil)
+					if ok {
+						store.MarkRunning(job.ID, 1)
+						store.MarkDone(job.ID, "55")
+					}
+				}()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(`{"name": "a", "value": 10, "delay": "0s"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			RequestHandler(rec, req, dispatcher, store, RequestOptions{MaxValue: 1000})
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d\n%s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			var got JobRecord
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode record: %v", err)
+			}
+			if got.Status != tc.wantState {
+				t.Errorf("status = %q; want %q", got.Status, tc.wantState)
+			}
+			if tc.wantState == StatusDone && (got.Result == nil || *got.Result != "55") {
+				t.Errorf("result = %v; want 55", got.Result)
+			}
+			if loc := rec.Header().Get("Location"); loc != "/fibonacci/"+got.ID {
+				t.Errorf("Location = %q; want /fibonacci/%s", loc, got.ID)
+			}
+		})
+	}
+}
+
+// TestRequestHandlerQueueFull verifica que con la cola llena se responda 503
+// con una cabecera Retry-After estimada a partir de la ocupación de la cola.
+func TestRequestHandlerQueueFull(t *testing.T) {
+	store := NewJobStore()
+	dispatcher := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+	if err := dispatcher.Enqueue(NewJob("first", 10, 0, time.Time{}, PriorityNormal), 0); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	store.avgDuration = 3 * time.Second // Con un worker, el nuevo trabajo esperaría dos turnos.
+
+	req := httptest.NewRequest(http.MethodPost, "/fibonacci", strings.NewReader(`{"name": "a", "value": 10, "delay": "0s"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	RequestHandler(rec, req, dispatcher, store, RequestOptions{MaxValue: 1000, EnqueueTimeout: 10 * time.Millisecond})
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d; want %d\n%s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"queue_full"`) {
+		t.Errorf("body = %s; want queue_full error", rec.Body)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "6" {
+		t.Errorf("Retry-After = %q; want %q", got, "6")
+	}
+	if got := dispatcher.JobQueue.Len(); got != 1 {
+		t.Errorf("JobQueue.Len() = %d; want 1", got)
+	}
+}
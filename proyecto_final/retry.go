@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy define cuántas veces se reintenta un trabajo fallido y cuánto
+// se espera entre intentos.
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts"`              // Número máximo de intentos, incluido el primero
+	Backoff        time.Duration `json:"backoff"`                   // Espera base antes del primer reintento
+	MaxBackoff     time.Duration `json:"max_backoff"`               // Tope de la espera entre reintentos
+	AttemptTimeout time.Duration `json:"attempt_timeout,omitempty"` // Duración máxima de cada intento, cero sin límite
+}
+
+// ErrAttemptTimedOut es la causa del contexto de un intento que superó AttemptTimeout.
+var ErrAttemptTimedOut = errors.New("attempt timed out")
+
+// Delay devuelve la espera antes del reintento que sigue al intento indicado
+// (1 es el primer intento). La espera se duplica en cada intento hasta
+// MaxBackoff y se le aplica un jitter aleatorio entre la mitad y el total,
+// para que los trabajos que fallaron a la vez no se reintenten a la vez.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.MaxBackoff
+	if shift := attempt - 1; shift < 63 && p.Backoff <= p.MaxBackoff>>shift {
+		d = p.Backoff << shift
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// parseRetryPolicy aplica sobre la política por defecto los campos
+// opcionales max_attempts, backoff y attempt_timeout de la solicitud,
+// anotando en errs los que no sean válidos.
+func parseRetryPolicy(req JobRequest, defaults RetryPolicy, errs FieldErrors) RetryPolicy {
+	policy := defaults
+	if req.MaxAttempts != nil {
+		if *req.MaxAttempts < 1 {
+			errs.Add("max_attempts", "must be at least 1")
+		}
+		policy.MaxAttempts = *req.MaxAttempts
+	}
+	if req.Backoff != "" {
+		d, err := time.ParseDuration(req.Backoff)
+		if err != nil || d <= 0 {
+			errs.Add("backoff", `must be a positive duration like "500ms"`)
+		}
+		policy.Backoff = d
+		policy.MaxBackoff = max(policy.MaxBackoff, d)
+	}
+	if req.AttemptTimeout != "" {
+		d, err := time.ParseDuration(req.AttemptTimeout)
+		if err != nil || d <= 0 {
+			errs.Add("attempt_timeout", `must be a positive duration like "5s"`)
+		}
+		policy.AttemptTimeout = d
+	}
+	return policy
+}
+
+// pendingRetry es un trabajo fallido a la espera de su siguiente intento.
+type pendingRetry struct {
+	job   Job
+	timer *time.Timer
+}
+
+// handleFailure decide qué hacer con un intento fallido: si el error no es
+// permanente y quedan intentos, programa un reintento tras la espera de la
+// política del trabajo; si no, lo marca como fallido y lo mueve a la cola
+// de mensajes muertos.
+func (d *Dispatcher) handleFailure(job Job, err error) {
+	if errors.Is(err, ErrNegativeNumber) || job.Attempt >= job.Retry.MaxAttempts {
+		if d.Store.MarkFailed(job.ID, err.Error()) && d.DeadLetters != nil {
+			d.DeadLetters.Add(job, err)
+			d.Logger.Error("job moved to dead letters", append(jobAttrs(job), logAttempt, job.Attempt, logError, err)...)
+		}
+		return
+	}
+
+	delay := job.Retry.Delay(job.Attempt)
+	if !d.Store.MarkRetrying(job.ID, err.Error(), time.Now().Add(delay)) {
+		return // El trabajo se canceló o expiró mientras fallaba.
+	}
+	d.Logger.Warn("retrying job", append(jobAttrs(job), logAttempt, job.Attempt+1, "max_attempts", job.Retry.MaxAttempts, "delay", delay)...)
+	job.Attempt++
+	d.scheduleRetry(job, delay)
+}
+
+// scheduleRetry vuelve a encolar el trabajo cuando transcurre delay. Si el
+// dispatcher se está deteniendo, el trabajo se anota como abandonado.
+func (d *Dispatcher) scheduleRetry(job Job, delay time.Duration) {
+	d.retryMu.Lock()
+	defer d.retryMu.Unlock()
+	if d.stopping {
+		d.abandonedRetries = append(d.abandonedRetries, job)
+		return
+	}
+
+	d.retries.Add(1)
+	timer := time.AfterFunc(delay, func() {
+		defer d.retries.Done()
+		d.retryMu.Lock()
+		delete(d.retrying, job.ID)
+		d.retryMu.Unlock()
+		if job.Context().Err() != nil {
+			return // Se canceló o expiró durante la espera.
+		}
+
+		err := d.JobQueue.Push(job, requeueWait)
+		for errors.Is(err, ErrQueueFull) {
+			err = d.JobQueue.Push(job, requeueWait)
+		}
+		if err != nil {
+			d.retryMu.Lock()
+			d.abandonedRetries = append(d.abandonedRetries, job)
+			d.retryMu.Unlock()
+		}
+	})
+	d.retrying[job.ID] = pendingRetry{job: job, timer: timer}
+}
+
+// abandonRetries cancela los reintentos programados y devuelve sus trabajos.
+// A partir de este momento no se programan reintentos nuevos. Los
+// temporizadores que ya vencieron siguen su curso: con la cola cerrada, su
+// trabajo acaba en takeAbandonedRetries.
+func (d *Dispatcher) abandonRetries() []Job {
+	d.retryMu.Lock()
+	defer d.retryMu.Unlock()
+	d.stopping = true
+	var jobs []Job
+	for id, retry := range d.retrying {
+		if retry.timer.Stop() {
+			jobs = append(jobs, retry.job)
+			delete(d.retrying, id)
+			d.retries.Done()
+		}
+	}
+	return jobs
+}
+
+// takeAbandonedRetries devuelve los reintentos que no pudieron programarse
+// o encolarse durante la parada. Debe llamarse después de esperar a retries.
+func (d *Dispatcher) takeAbandonedRetries() []Job {
+	d.retryMu.Lock()
+	defer d.retryMu.Unlock()
+	jobs := d.abandonedRetries
+	d.abandonedRetries = nil
+	return jobs
+}
@@ -0,0 +1,74 @@
+// Este archivo contiene pruebas unitarias para la política de reintentos:
+// la espera entre intentos y el destino de los intentos fallidos. El
+// dispatcher no se arranca: los fallos se simulan llamando a handleFailure.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRetryPolicyDelay verifica que la espera se duplique en cada intento
+// hasta MaxBackoff y que el jitter la deje entre la mitad y el total.
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	testCases := []struct {
+		attempt int
+		want    time.Duration // Espera sin jitter
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 100, want: time.Second},
+	}
+	for _, tc := range testCases {
+		for range 100 {
+			if got := policy.Delay(tc.attempt); got < tc.want/2 || got > tc.want {
+				t.Fatalf("Delay(%d) = %v; want between %v and %v", tc.attempt, got, tc.want/2, tc.want)
+			}
+		}
+	}
+}
+
+// TestHandleFailure verifica que un intento fallido se reintente mientras
+// queden intentos y que después el trabajo pase a la cola de mensajes
+// muertos, igual que con un error permanente.
+func TestHandleFailure(t *testing.T) {
+	store := NewJobStore()
+	d := NewDispatcher(NewJobQueue(1, 0, time.Second), 1, store, nil)
+	job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+	job.Retry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	store.Add(job)
+	store.MarkRunning(job.ID, 1)
+
+	d.handleFailure(job, errors.New("boom"))
+	if rec, _ := store.Get(job.ID); rec.Status != StatusRetrying {
+		t.Fatalf("status after first failure = %q; want %q", rec.Status, StatusRetrying)
+	}
+	quit := make(chan struct{})
+	time.AfterFunc(time.Second, func() { close(quit) })
+	retried, ok := d.JobQueue.Pop(quit)
+	if !ok || retried.ID != job.ID || retried.Attempt != 2 {
+		t.Fatalf("Pop() = %+v, %v; want the job on attempt 2", retried, ok)
+	}
+
+	store.MarkRunning(job.ID, 1)
+	d.handleFailure(retried, errors.New("boom again"))
+	if rec, _ := store.Get(job.ID); rec.Status != StatusFailed || rec.Error != "boom again" {
+		t.Errorf("status after last attempt = %q (%s); want %q", rec.Status, rec.Error, StatusFailed)
+	}
+	if dl, ok := d.DeadLetters.Get(job.ID); !ok || dl.Attempts != 2 || dl.LastError != "boom again" {
+		t.Errorf("dead letter = %+v, %v; want 2 attempts ending in boom again", dl, ok)
+	}
+
+	permanent := NewJob("negative", 10, 0, time.Time{}, PriorityNormal)
+	permanent.Retry = job.Retry
+	store.Add(permanent)
+	d.handleFailure(permanent, ErrNegativeNumber)
+	if _, ok := d.DeadLetters.Get(permanent.ID); !ok {
+		t.Error("permanent error not moved to dead letters")
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JobStatus representa el estado en el que se encuentra un trabajo
+// dentro de su ciclo de vida.
+type JobStatus string
+
+const (
+	StatusQueued    JobStatus = "queued"    // El trabajo espera a un worker disponible
+	StatusRunning   JobStatus = "running"   // Un worker está procesando el trabajo
+	StatusRetrying  JobStatus = "retrying"  // Un intento falló y se reintentará tras una espera
+	StatusDone      JobStatus = "done"      // El trabajo terminó correctamente
+	StatusFailed    JobStatus = "failed"    // El trabajo terminó con error
+	StatusCancelled JobStatus = "cancelled" // El trabajo fue cancelado por el cliente
+	StatusTimedOut  JobStatus = "timed_out" // El trabajo superó su fecha límite
+)
+
+// Terminal indica si el estado es final y el trabajo ya no cambiará.
+func (s JobStatus) Terminal() bool {
+	return s != StatusQueued && s != StatusRunning && s != StatusRetrying
+}
+
+var (
+	// ErrJobNotFound se devuelve cuando no existe un trabajo con el ID indicado.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished se devuelve al cancelar un trabajo que ya terminó.
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobCancelled es la causa del contexto de un trabajo cancelado.
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrJobTimedOut es la causa del contexto de un trabajo que superó su fecha límite.
+	ErrJobTimedOut = errors.New("job timed out")
+)
+
+// JobRecord contiene el estado consultable de un trabajo: sus datos de entrada,
+// el estado actual, las marcas de tiempo de cada transición y el resultado.
+type JobRecord struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Number        int        `json:"number"`
+	Priority      Priority   `json:"priority"`
+	Status        JobStatus  `json:"status"`
+	Attempts      int        `json:"attempts"`
+	Worker        *int       `json:"worker,omitempty"`
+	CoalescedWith string     `json:"coalesced_with,omitempty"`
+	Result        *string    `json:"result,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	Deadline      *time.Time `json:"deadline,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	NextRetry     *time.Time `json:"next_retry_at,omitempty"`
+	Callback      *Callback  `json:"callback,omitempty"`
+	Owner         string     `json:"owner,omitempty"`
+
+	done   chan struct{}           // Se cierra cuando el trabajo alcanza un estado final
+	cancel context.CancelCauseFunc // Cancela el contexto del trabajo
+}
+
+// JobStore almacena en memoria el registro de todos los trabajos aceptados
+// por el servidor. Es seguro para uso concurrente entre el RequestHandler
+// y los workers.
+//
+// Con Retention, los registros de los trabajos terminados se olvidan al
+// cabo de ese tiempo para que el store no crezca indefinidamente; a partir
+// de entonces el trabajo se consulta como si no existiera. Retention debe
+// fijarse antes de aceptar trabajos.
+type JobStore struct {
+	Retention time.Duration // Tiempo que se conservan los trabajos terminados, cero para siempre
+
+	mu          sync.RWMutex
+	records     map[string]*JobRecord
+	lastSweep   time.Time
+	avgDuration time.Duration // Media móvil exponencial de la duración de ejecución
+	observers   []func(JobRecord)
+	forgetters  []func([]JobRecord)
+}
+
+// durationSmoothing es el peso de la última duración observada en la media móvil.
+const durationSmoothing = 0.2
+
+// NewJobStore crea un JobStore vacío.
+func NewJobStore() *JobStore {
+	return &JobStore{
+		records: make(map[string]*JobRecord),
+	}
+}
+
+// Observe registra una función que recibe una copia del registro cada vez que
+// un trabajo cambia de estado, incluido su alta en estado queued. Las
+// funciones se llaman fuera del lock, en la goroutine que provoca el cambio,
+// por lo que deben ser rápidas. Debe llamarse antes de aceptar trabajos.
+func (s *JobStore) Observe(fn func(JobRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
+// ObserveForget registra una función que recibe los registros que el store
+// olvida por Retention. Las funciones se llaman con el lock del store tomado,
+// de modo que nadie ve el trabajo olvidado antes de que lo reciban, y no
+// deben usar el JobStore. Debe llamarse antes de aceptar trabajos.
+func (s *JobStore) ObserveForget(fn func([]JobRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forgetters = append(s.forgetters, fn)
+}
+
+// notify entrega rec a todos los observadores registrados.
+func (s *JobStore) notify(rec JobRecord) {
+	s.mu.RLock()
+	observers := s.observers
+	s.mu.RUnlock()
+	for _, fn := range observers {
+		fn(rec)
+	}
+}
+
+// NewJobID genera un identificador aleatorio de 16 caracteres hexadecimales.
+func NewJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand no falla en las plataformas soportadas.
+	}
+	return hex.EncodeToString(b)
+}
+
+// Add registra un trabajo recién aceptado en estado queued. El trabajo debe
+// haberse creado con NewJob para poder cancelarlo. Si el trabajo tiene fecha
+// límite, se marca como timed_out en cuanto expire, esté en cola o en ejecución.
+// De paso olvida los trabajos terminados que superaron Retention.
+func (s *JobStore) Add(job Job) {
+	s.mu.Lock()
+	rec := &JobRecord{
+		ID:        job.ID,
+		Name:      job.Name,
+		Number:    job.Number,
+		Priority:  job.Priority,
+		Owner:     job.Owner,
+		Status:    StatusQueued,
+		CreatedAt: time.Now(),
+		done:      make(chan struct{}),
+		cancel:    job.cancel,
+	}
+	if !job.Deadline.IsZero() {
+		deadline := job.Deadline
+		rec.Deadline = &deadline
+	}
+	if job.CallbackURL != "" {
+		rec.Callback = &Callback{URL: job.CallbackURL, State: CallbackPending}
+	}
+	s.sweep(rec.CreatedAt)
+	s.records[job.ID] = rec
+	snapshot := *rec
+	s.mu.Unlock()
+	s.notify(snapshot)
+
+	if job.ctx != nil {
+		context.AfterFunc(job.ctx, func() {
+			if errors.Is(context.Cause(job.ctx), ErrJobTimedOut) {
+				s.MarkTimedOut(job.ID)
+			}
+		})
+	}
+}
+
+// SetCoalescedWith anota que el trabajo comparte la ejecución del trabajo
+// leaderID, o que vuelve a ejecutarse por sí mismo si leaderID está vacío.
+// No es una transición de estado, por lo que no se notifica a los observadores.
+func (s *JobStore) SetCoalescedWith(id, leaderID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[id]; ok {
+		rec.CoalescedWith = leaderID
+	}
+}
+
+// AddDeliveryAttempt anota un intento de entrega del callback del trabajo y
+// el estado resultante. Como SetCoalescedWith, no notifica a los observadores.
+func (s *JobStore) AddDeliveryAttempt(id string, attempt DeliveryAttempt, state CallbackState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok || rec.Callback == nil {
+		return
+	}
+	// Los registros devueltos por Get comparten el puntero: se reemplaza en
+	// lugar de modificarlo.
+	cb := *rec.Callback
+	cb.State = state
+	cb.Attempts = append(slices.Clip(cb.Attempts), attempt)
+	rec.Callback = &cb
+}
+
+// Get devuelve una copia del registro del trabajo con el ID indicado.
+// El segundo valor es false si el trabajo no existe.
+func (s *JobStore) Get(id string) (JobRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return JobRecord{}, false
+	}
+	return *rec, true
+}
+
+// Done devuelve un canal que se cierra cuando el trabajo termina, ya sea
+// correctamente o con error. El segundo valor es false si el trabajo no existe.
+func (s *JobStore) Done(id string) (<-chan struct{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, false
+	}
+	return rec.done, true
+}
+
+// MarkRunning marca el trabajo como en ejecución en el worker indicado e
+// incrementa su número de intentos.
+func (s *JobStore) MarkRunning(id string, worker int) {
+	s.update(id, func(rec *JobRecord) {
+		now := time.Now()
+		rec.Status = StatusRunning
+		rec.Worker = &worker
+		rec.StartedAt = &now
+		rec.Attempts++
+		rec.NextRetry = nil
+	})
+}
+
+// MarkRetrying marca que un intento del trabajo falló con el error indicado y
+// que se reintentará en next. Retorna false si el trabajo ya había terminado.
+func (s *JobStore) MarkRetrying(id string, errMsg string, next time.Time) bool {
+	return s.update(id, func(rec *JobRecord) {
+		rec.Status = StatusRetrying
+		rec.Error = errMsg
+		rec.NextRetry = &next
+	})
+}
+
+// MarkDone marca el trabajo como terminado y guarda su resultado.
+func (s *JobStore) MarkDone(id string, result string) {
+	s.update(id, func(rec *JobRecord) {
+		rec.Result = &result
+		rec.Error = ""
+		s.finish(rec, StatusDone)
+	})
+}
+
+// MarkFailed marca el trabajo como fallido con el mensaje de error indicado.
+// Retorna false si el trabajo ya había terminado, por ejemplo por cancelación.
+func (s *JobStore) MarkFailed(id string, errMsg string) bool {
+	return s.update(id, func(rec *JobRecord) {
+		rec.Error = errMsg
+		s.finish(rec, StatusFailed)
+	})
+}
+
+// MarkTimedOut marca el trabajo como expirado por superar su fecha límite.
+func (s *JobStore) MarkTimedOut(id string) {
+	s.update(id, func(rec *JobRecord) {
+		rec.Error = ErrJobTimedOut.Error()
+		s.finish(rec, StatusTimedOut)
+	})
+}
+
+// Cancel cancela un trabajo en cola o en ejecución: lo marca como cancelado y
+// cancela su contexto para que el dispatcher lo descarte o el worker lo
+// interrumpa. Retorna ErrJobNotFound o ErrJobFinished si no puede cancelarse.
+func (s *JobStore) Cancel(id string) (JobRecord, error) {
+	s.mu.Lock()
+	rec, ok := s.records[id]
+	if !ok {
+		s.mu.Unlock()
+		return JobRecord{}, ErrJobNotFound
+	}
+	if rec.Status.Terminal() {
+		s.mu.Unlock()
+		return *rec, ErrJobFinished
+	}
+	if rec.cancel != nil {
+		rec.cancel(ErrJobCancelled)
+	}
+	s.finish(rec, StatusCancelled)
+	snapshot := *rec
+	s.mu.Unlock()
+
+	s.notify(snapshot)
+	return snapshot, nil
+}
+
+// finish lleva el registro a un estado final, notifica a quienes esperan el
+// resultado y libera su contexto. Debe llamarse con el lock de escritura tomado.
+func (s *JobStore) finish(rec *JobRecord, status JobStatus) {
+	now := time.Now()
+	rec.Status = status
+	rec.FinishedAt = &now
+	close(rec.done)
+	if rec.cancel != nil {
+		rec.cancel(context.Canceled)
+	}
+	if status == StatusDone || status == StatusFailed {
+		s.observeDuration(rec)
+	}
+}
+
+// AverageDuration devuelve la duración media de ejecución de los trabajos
+// terminados recientemente, o cero si todavía no ha terminado ninguno.
+func (s *JobStore) AverageDuration() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.avgDuration
+}
+
+// sweep olvida los trabajos que terminaron hace más de Retention y los
+// entrega a las funciones de ObserveForget. Se ejecuta como mucho una vez
+// cada Retention, así que un registro puede durar hasta el doble. Debe
+// llamarse con el lock de escritura tomado.
+func (s *JobStore) sweep(now time.Time) {
+	if s.Retention <= 0 || now.Sub(s.lastSweep) < s.Retention {
+		return
+	}
+	s.lastSweep = now
+	var forgotten []JobRecord
+	for id, rec := range s.records {
+		if rec.Status.Terminal() && now.Sub(*rec.FinishedAt) >= s.Retention {
+			delete(s.records, id)
+			forgotten = append(forgotten, *rec)
+		}
+	}
+	if len(forgotten) > 0 {
+		for _, fn := range s.forgetters {
+			fn(forgotten)
+		}
+	}
+}
+
+// observeDuration incorpora la duración de un trabajo terminado a la media
+// móvil. Debe llamarse con el lock de escritura tomado.
+func (s *JobStore) observeDuration(rec *JobRecord) {
+	if rec.StartedAt == nil {
+		return
+	}
+	d := rec.FinishedAt.Sub(*rec.StartedAt)
+	if s.avgDuration == 0 {
+		s.avgDuration = d
+		return
+	}
+	s.avgDuration = time.Duration(durationSmoothing*float64(d) + (1-durationSmoothing)*float64(s.avgDuration))
+}
+
+// update aplica fn sobre el registro del trabajo bajo el lock de escritura y
+// notifica el cambio a los observadores. Si el trabajo no existe o ya está en
+// un estado final no hace nada y retorna false, de modo que un worker no puede
+// sobrescribir, por ejemplo, una cancelación.
+func (s *JobStore) update(id string, fn func(rec *JobRecord)) bool {
+	s.mu.Lock()
+	rec, ok := s.records[id]
+	if !ok || rec.Status.Terminal() {
+		s.mu.Unlock()
+		return false
+	}
+	fn(rec)
+	snapshot := *rec
+	s.mu.Unlock()
+
+	s.notify(snapshot)
+	return true
+}
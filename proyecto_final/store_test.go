@@ -0,0 +1,51 @@
+// Este archivo contiene pruebas unitarias para el registro de trabajos. Los
+// trabajos se dan de alta y se terminan directamente sobre el JobStore.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestJobStoreRetention verifica que los trabajos terminados se olviden al
+// superar Retention, que los pendientes se conserven, que el progreso de un
+// lote conserve el estado final de los trabajos olvidados y que el lote
+// caduque al olvidarse todos sus trabajos.
+func TestJobStoreRetention(t *testing.T) {
+	store := NewJobStore()
+	store.Retention = 20 * time.Millisecond
+	finished := NewJob("finished", 10, 0, time.Time{}, PriorityNormal)
+	pending := NewJob("pending", 11, 0, time.Time{}, PriorityNormal)
+	store.Add(finished)
+	store.Add(pending)
+	store.MarkDone(finished.ID, "55")
+	batches := NewBatchStore()
+	store.ObserveForget(batches.forget)
+	batch := batches.Add([]string{finished.ID, pending.ID}, "")
+
+	store.Add(NewJob("early", 12, 0, time.Time{}, PriorityNormal))
+	if _, ok := store.Get(finished.ID); !ok {
+		t.Fatal("finished job dropped before Retention")
+	}
+
+	time.Sleep(2 * store.Retention)
+	store.Add(NewJob("late", 13, 0, time.Time{}, PriorityNormal))
+	if _, ok := store.Get(finished.ID); ok {
+		t.Error("finished job kept after Retention")
+	}
+	if _, ok := store.Get(pending.ID); !ok {
+		t.Error("pending job dropped")
+	}
+	p, ok := batches.Progress(batch.ID, store)
+	if !ok || p.Finished != 1 || p.Counts[StatusDone] != 1 || p.Counts[StatusQueued] != 1 || p.Done {
+		t.Errorf("progress = %+v, %v; want 1 of 2 done", p, ok)
+	}
+
+	store.MarkDone(pending.ID, "89")
+	time.Sleep(2 * store.Retention)
+	store.Add(NewJob("later", 14, 0, time.Time{}, PriorityNormal))
+	if p, ok := batches.Progress(batch.ID, store); ok {
+		t.Errorf("progress = %+v; want expired batch", p)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader sirve el certificado del servidor y lo vuelve a leer de disco
+// cuando sus archivos cambian, para rotarlo sin reiniciar. Los archivos se
+// comprueban durante los handshakes, como mucho una vez cada interval. Si la
+// lectura falla, por ejemplo a mitad de una rotación, se sigue sirviendo el
+// certificado anterior. Es seguro para uso concurrente.
+type CertReloader struct {
+	Logger *slog.Logger
+
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // Última modificación de los archivos del certificado servido
+	checked time.Time // Última comprobación de los archivos
+}
+
+// NewCertReloader lee el certificado y su clave privada en PEM.
+//
+// Parámetros:
+//   - certFile: Certificado, seguido de los intermedios de la cadena
+//   - keyFile: Clave privada del certificado
+//   - interval: Tiempo mínimo entre comprobaciones de los archivos
+//
+// Retorna:
+//   - *CertReloader: Nuevo reloader con el certificado cargado
+//   - error: Si el certificado o la clave no se pueden leer
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	c := &CertReloader{Logger: slog.Default(), certFile: certFile, keyFile: keyFile, interval: interval}
+	modTime, err := c.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(modTime); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate devuelve el certificado vigente. Se asigna a
+// tls.Config.GetCertificate.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= c.interval {
+		c.checked = now
+		modTime, err := c.lastModified()
+		if err == nil && !modTime.Equal(c.modTime) {
+			err = c.load(modTime)
+		}
+		if err != nil {
+			c.Logger.Warn("could not reload TLS certificate, serving the previous one", logError, err)
+		}
+	}
+	return c.cert, nil
+}
+
+// lastModified devuelve la última modificación de los dos archivos.
+func (c *CertReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// load lee el certificado y lo pone en servicio. Debe llamarse con mu tomado
+// o antes de compartir el reloader.
+func (c *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	if c.cert != nil {
+		c.Logger.Info("TLS certificate reloaded", "subject", cert.Leaf.Subject.String(), "not_after", cert.Leaf.NotAfter)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// NewTLSConfig crea la configuración TLS del servidor. Con clientCAFile,
+// activa mTLS: solo se aceptan conexiones con un certificado de cliente
+// firmado por alguna de esas CA.
+func NewTLSConfig(certs *CertReloader, clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA file contains no PEM certificates")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
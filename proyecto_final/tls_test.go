@@ -0,0 +1,232 @@
+// Este archivo contiene pruebas unitarias para TLS: la recarga del
+// certificado del servidor cuando se rota y la identidad de los clientes que
+// se autentican con un certificado en mTLS. Los certificados se generan en
+// cada prueba.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert es un certificado generado para las pruebas, con su clave.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert genera un certificado de commonName firmado por parent, o una
+// CA autofirmada si parent es nil. Los certificados finales valen para
+// servidor en 127.0.0.1 y para cliente.
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Acme"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeCert guarda el certificado y su clave en dir y devuelve sus rutas. La
+// fecha de modificación se fija en modTime para no depender de la
+// resolución del reloj del sistema de archivos.
+func writeCert(t *testing.T, dir string, c *testCert, modTime time.Time) (certFile, keyFile string) {
+	t.Helper()
+	certFile, keyFile = filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	writeFile(t, certFile, string(c.certPEM))
+	writeFile(t, keyFile, string(c.keyPEM))
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+// TestCertReloader verifica que el certificado se recargue al rotarse y que
+// se siga sirviendo el anterior si el nuevo no se puede leer.
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test ca", nil)
+	first, second := newTestCert(t, "first", ca), newTestCert(t, "second", ca)
+	modTime := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeCert(t, dir, first, modTime)
+
+	certs, err := NewCertReloader(certFile, keyFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs.Logger, _ = NewLogger(io.Discard, "text", "info")
+
+	testCases := []struct {
+		name   string
+		rotate func()
+		want   string
+	}{
+		{name: "initial", rotate: func() {}, want: "first"},
+		{name: "rotated", rotate: func() { writeCert(t, dir, second, modTime.Add(time.Second)) }, want: "second"},
+		{
+			name: "broken rotation keeps previous",
+			rotate: func() {
+				writeCert(t, dir, &testCert{certPEM: first.certPEM, keyPEM: second.keyPEM}, modTime.Add(2*time.Second))
+			},
+			want: "second",
+		},
+		{name: "fixed", rotate: func() { writeCert(t, dir, first, modTime.Add(3*time.Second)) }, want: "first"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rotate()
+			cert, err := certs.GetCertificate(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cert.Leaf.Subject.CommonName; got != tc.want {
+				t.Errorf("served certificate = %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile, 0); err == nil {
+		t.Error("NewCertReloader() with a missing file error = nil; want error")
+	}
+}
+
+// TestMutualTLS verifica que con mTLS se rechacen las conexiones sin un
+// certificado de cliente de la CA, y que el sujeto del certificado se
+// traduzca en la identidad registrada.
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, rogueCA := newTestCert(t, "test ca", nil), newTestCert(t, "rogue ca", nil)
+	server := newTestCert(t, "server", ca)
+	alice, mallory, rogue := newTestCert(t, "alice", ca), newTestCert(t, "mallory", ca), newTestCert(t, "alice", rogueCA)
+	certFile, keyFile := writeCert(t, dir, server, time.Now())
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, string(ca.certPEM))
+
+	auth, err := NewAuthenticator([]APIKey{
+		{Name: "alice", Subject: "CN=alice,O=Acme", Scopes: []string{ScopeJobsRead}},
+		{Name: "bob", Hash: HashAPIKey("bob-key"), Scopes: []string{ScopeJobsRead}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := NewCertReloader(certFile, keyFile, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(auth.Require(ScopeJobsRead, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(requestOwner(r)))
+	}))
+	config, err := NewTLSConfig(certs, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// StartTLS añadiría su propio certificado, que tendría prioridad sobre
+	// GetCertificate; se sirve TLS con la configuración tal como en main.
+	ts.Listener = tls.NewListener(ts.Listener, config)
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // Los handshakes rechazados no son errores de la prueba.
+	ts.Start()
+	defer ts.Close()
+	url := "https://" + ts.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	testCases := []struct {
+		name       string
+		clientCert *testCert
+		apiKey     string
+		wantErr    bool
+		wantStatus int
+		wantOwner  string
+	}{
+		{name: "no certificate", wantErr: true},
+		{name: "certificate from another CA", clientCert: rogue, wantErr: true},
+		{name: "registered subject", clientCert: alice, wantStatus: http.StatusOK, wantOwner: "alice"},
+		{name: "unregistered subject", clientCert: mallory, wantStatus: http.StatusUnauthorized},
+		{name: "unregistered subject with api key", clientCert: mallory, apiKey: "bob-key", wantStatus: http.StatusOK, wantOwner: "bob"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &tls.Config{RootCAs: roots}
+			if tc.clientCert != nil {
+				pair, err := tls.X509KeyPair(tc.clientCert.certPEM, tc.clientCert.keyPEM)
+				if err != nil {
+					t.Fatal(err)
+				}
+				config.Certificates = []tls.Certificate{pair}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			resp, err := client.Do(req)
+			if tc.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with status %d; want a TLS error", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", resp.StatusCode, tc.wantStatus, body)
+			}
+			if tc.wantOwner != "" && string(body) != tc.wantOwner {
+				t.Errorf("owner = %q; want %q", body, tc.wantOwner)
+			}
+		})
+	}
+}
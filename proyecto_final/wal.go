@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Operaciones registradas en el write-ahead log.
+const (
+	walAccept = "accept" // Trabajo aceptado por el servidor
+	walFinish = "finish" // Trabajo terminado o descartado
+)
+
+// walEntry es una línea JSON del write-ahead log.
+type walEntry struct {
+	Op       string        `json:"op"`
+	ID       string        `json:"id"`
+	Name     string        `json:"name,omitempty"`
+	Number   int           `json:"number,omitempty"`
+	Delay    time.Duration `json:"delay,omitempty"`
+	Deadline *time.Time    `json:"deadline,omitempty"`
+	Priority Priority      `json:"priority,omitempty"`
+	Retry    *RetryPolicy  `json:"retry,omitempty"`
+	Callback string        `json:"callback_url,omitempty"`
+	Owner    string        `json:"owner,omitempty"`
+	Status   JobStatus     `json:"status,omitempty"`
+
+	seq int // Orden de aceptación, usado al compactar y reproducir
+}
+
+// job reconstruye el trabajo aceptado descrito por la entrada, conservando su ID.
+func (e walEntry) job() Job {
+	var deadline time.Time
+	if e.Deadline != nil {
+		deadline = *e.Deadline
+	}
+	job := NewJob(e.Name, e.Number, e.Delay, deadline, e.Priority)
+	job.ID = e.ID
+	if e.Retry != nil {
+		job.Retry = *e.Retry
+	}
+	job.CallbackURL = e.Callback
+	job.Owner = e.Owner
+	return job
+}
+
+// WAL es un write-ahead log en disco que permite recuperar tras un reinicio
+// los trabajos aceptados que no llegaron a terminar.
+//
+// Cada trabajo aceptado se anota con Append antes de encolarse y se marca con
+// Finish al alcanzar un estado final. Compact reescribe el archivo dejando
+// solo los trabajos pendientes para que no crezca indefinidamente.
+type WAL struct {
+	Logger *slog.Logger // Logger donde se registran las entradas corruptas y los fallos al compactar
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	pending map[string]walEntry // Trabajos aceptados y no terminados
+	seq     int
+}
+
+// OpenWAL abre (o crea) el write-ahead log en path, lo compacta y devuelve
+// los trabajos aceptados que no terminaron, en el orden en que se aceptaron,
+// para que el llamador los vuelva a encolar.
+//
+// Parámetros:
+//   - path: Ruta del archivo del log
+//   - logger: Logger del log, o nil para el de slog por defecto
+//
+// Retorna:
+//   - *WAL: Log abierto y compactado
+//   - []Job: Trabajos aceptados que no terminaron
+//   - error: Error al leer o compactar el archivo
+func OpenWAL(path string, logger *slog.Logger) (*WAL, []Job, error) {
+	w := &WAL{
+		Logger:  loggerOrDefault(logger),
+		path:    path,
+		pending: make(map[string]walEntry),
+	}
+	if err := w.load(); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Compact(); err != nil {
+		return nil, nil, err
+	}
+
+	entries := w.sortedPending()
+	jobs := make([]Job, len(entries))
+	for i, e := range entries {
+		jobs[i] = e.job()
+	}
+	return w, jobs, nil
+}
+
+// load lee el archivo existente reconstruyendo el conjunto de trabajos pendientes.
+// Una última línea truncada por una caída se ignora.
+func (w *WAL) load() error {
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			w.Logger.Warn("skipping corrupt WAL entry", logError, err)
+			continue
+		}
+		switch e.Op {
+		case walAccept:
+			if _, ok := w.pending[e.ID]; !ok {
+				w.seq++
+				e.seq = w.seq
+				w.pending[e.ID] = e
+			}
+		case walFinish:
+			delete(w.pending, e.ID)
+		}
+	}
+	return scanner.Err()
+}
+
+// Append anota uno o varios trabajos aceptados y sincroniza el archivo a
+// disco una sola vez, de modo que los trabajos sobreviven a una caída en
+// cuanto Append retorna.
+func (w *WAL) Append(jobs ...Job) error {
+	entries := make([]walEntry, len(jobs))
+	var buf []byte
+	for i, job := range jobs {
+		e := walEntry{
+			Op:       walAccept,
+			ID:       job.ID,
+			Name:     job.Name,
+			Number:   job.Number,
+			Delay:    job.Delay,
+			Priority: job.Priority,
+			Retry:    &job.Retry,
+			Callback: job.CallbackURL,
+			Owner:    job.Owner,
+		}
+		if !job.Deadline.IsZero() {
+			e.Deadline = &job.Deadline
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+		entries[i] = e
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		w.seq++
+		e.seq = w.seq
+		w.pending[e.ID] = e
+	}
+	return nil
+}
+
+// Finish anota que el trabajo alcanzó el estado final indicado y ya no debe
+// reproducirse al reiniciar. Ignora trabajos que no estén pendientes.
+func (w *WAL) Finish(id string, status JobStatus) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.pending[id]; !ok {
+		return nil
+	}
+	delete(w.pending, id)
+	return w.write(walEntry{Op: walFinish, ID: id, Status: status})
+}
+
+// Compact reescribe el archivo con solo los trabajos pendientes. La escritura
+// se hace en un archivo temporal que reemplaza al original de forma atómica.
+func (w *WAL) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No hace nada si el rename tuvo éxito.
+
+	enc := json.NewEncoder(tmp)
+	for _, e := range w.sortedPending() {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return err
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	return err
+}
+
+// RunCompaction compacta el log cada interval hasta que se cierre quit.
+// Este método bloquea y debe ejecutarse en una goroutine separada.
+func (w *WAL) RunCompaction(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Compact(); err != nil {
+				w.Logger.Error("could not compact WAL", logError, err)
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Close cierra el archivo del log.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// write serializa e como una línea JSON. Debe llamarse con el lock tomado.
+func (w *WAL) write(e walEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(append(b, '\n'))
+	return err
+}
+
+// sortedPending devuelve los trabajos pendientes en orden de aceptación.
+func (w *WAL) sortedPending() []walEntry {
+	entries := make([]walEntry, 0, len(w.pending))
+	for _, e := range w.pending {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b walEntry) int { return a.seq - b.seq })
+	return entries
+}
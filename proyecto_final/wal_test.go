@@ -0,0 +1,165 @@
+// Este archivo contiene pruebas unitarias para el write-ahead log. Cada
+// prueba usa un archivo en un directorio temporal y simula los reinicios
+// cerrando el log y volviéndolo a abrir con OpenWAL.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestWAL abre el log en path descartando sus mensajes.
+func openTestWAL(t *testing.T, path string) (*WAL, []Job) {
+	t.Helper()
+	logger, _ := NewLogger(io.Discard, "text", "info")
+	wal, jobs, err := OpenWAL(path, logger)
+	if err != nil {
+		t.Fatalf("OpenWAL() error = %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	return wal, jobs
+}
+
+// jobNames devuelve los nombres de los trabajos separados por comas.
+func jobNames(jobs []Job) string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = job.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// TestWALReplay verifica que tras un reinicio se recuperen, en orden de
+// aceptación y con sus datos, solo los trabajos que no terminaron, y que un
+// trabajo terminado tras reproducirse no se vuelva a reproducir.
+func TestWALReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	deadline := time.Now().Add(time.Hour)
+	first := NewJob("first", 10, time.Second, deadline, PriorityHigh)
+	first.Owner = "alice"
+	first.CallbackURL = "https://example.com/hook"
+	first.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Minute}
+	second := NewJob("second", 11, 0, time.Time{}, PriorityNormal)
+	third := NewJob("third", 12, 0, time.Time{}, PriorityLow)
+
+	wal, jobs := openTestWAL(t, path)
+	if len(jobs) != 0 {
+		t.Fatalf("OpenWAL() on a new file returned %d job(s); want 0", len(jobs))
+	}
+	if err := wal.Append(first, second); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := wal.Append(third); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := wal.Finish(second.ID, StatusDone); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	wal.Close()
+
+	wal, jobs = openTestWAL(t, path)
+	if got := jobNames(jobs); got != "first,third" {
+		t.Fatalf("replayed jobs = %s; want first,third", got)
+	}
+	got := jobs[0]
+	if got.ID != first.ID || got.Number != 10 || got.Delay != time.Second || !got.Deadline.Equal(deadline) ||
+		got.Priority != PriorityHigh || got.Owner != "alice" || got.CallbackURL != first.CallbackURL || got.Retry != first.Retry {
+		t.Errorf("replayed job = %+v; want the fields of %+v", got, first)
+	}
+	if err := wal.Finish(first.ID, StatusDone); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if err := wal.Finish(first.ID, StatusDone); err != nil {
+		t.Errorf("Finish() twice error = %v; want nil", err)
+	}
+	wal.Close()
+
+	_, jobs = openTestWAL(t, path)
+	if got := jobNames(jobs); got != "third" {
+		t.Errorf("replayed jobs after second restart = %s; want third", got)
+	}
+}
+
+// TestWALTruncatedLine verifica que una última línea truncada por una caída
+// se ignore y desaparezca al compactar al abrir el log.
+func TestWALTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	wal, _ := openTestWAL(t, path)
+	job := NewJob("complete", 10, 0, time.Time{}, PriorityNormal)
+	if err := wal.Append(job); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	wal.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"accept","id":"trunc`)
+	f.Close()
+
+	wal, jobs := openTestWAL(t, path)
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Fatalf("replayed jobs = %s; want complete", jobNames(jobs))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("trunc")) {
+		t.Errorf("WAL after open = %s; want the truncated line removed", data)
+	}
+	// El log sigue admitiendo escrituras tras descartar la línea.
+	if err := wal.Append(NewJob("next", 11, 0, time.Time{}, PriorityNormal)); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	wal.Close()
+	if _, jobs = openTestWAL(t, path); jobNames(jobs) != "complete,next" {
+		t.Errorf("replayed jobs = %s; want complete,next", jobNames(jobs))
+	}
+}
+
+// TestWALCompact verifica que Compact deje en el archivo solo los trabajos
+// pendientes y que el log siga admitiendo escrituras después.
+func TestWALCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.wal")
+	wal, _ := openTestWAL(t, path)
+	jobs := []Job{
+		NewJob("first", 10, 0, time.Time{}, PriorityNormal),
+		NewJob("second", 11, 0, time.Time{}, PriorityNormal),
+		NewJob("third", 12, 0, time.Time{}, PriorityNormal),
+	}
+	if err := wal.Append(jobs...); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	for _, job := range jobs[:2] {
+		if err := wal.Finish(job.ID, StatusDone); err != nil {
+			t.Fatalf("Finish() error = %v", err)
+		}
+	}
+	if err := wal.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 || !bytes.Contains(data, []byte(jobs[2].ID)) {
+		t.Errorf("WAL after Compact = %s; want only the third job", data)
+	}
+
+	if err := wal.Append(NewJob("fourth", 13, 0, time.Time{}, PriorityNormal)); err != nil {
+		t.Fatalf("Append() after Compact error = %v", err)
+	}
+	wal.Close()
+	if _, replayed := openTestWAL(t, path); jobNames(replayed) != "third,fourth" {
+		t.Errorf("replayed jobs = %s; want third,fourth", jobNames(replayed))
+	}
+}
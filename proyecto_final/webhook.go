@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CallbackState es el estado de la entrega del callback de un trabajo.
+type CallbackState string
+
+const (
+	CallbackPending   CallbackState = "pending"   // Aún no se ha entregado
+	CallbackDelivered CallbackState = "delivered" // El receptor respondió 2xx
+	CallbackFailed    CallbackState = "failed"    // Se agotaron los intentos
+)
+
+// Callback es la URL a la que se notifica el resultado de un trabajo junto
+// con el estado de la entrega y sus intentos.
+type Callback struct {
+	URL      string            `json:"url"`
+	State    CallbackState     `json:"state"`
+	Attempts []DeliveryAttempt `json:"attempts,omitempty"`
+}
+
+// DeliveryAttempt es un intento de entrega de un callback.
+type DeliveryAttempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"` // Respuesta del receptor, cero si no la hubo
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Cabeceras de las notificaciones de los callbacks.
+const (
+	SignatureHeader = "X-Fibonacci-Signature" // t=<unix>,v1=<hex de HMAC-SHA256>
+	EventHeader     = "X-Fibonacci-Event"     // Tipo del evento, ej: "job.done"
+	DeliveryHeader  = "X-Fibonacci-Delivery"  // ID de la notificación, igual en todos sus intentos
+)
+
+// Sign calcula la firma de una notificación: el HMAC-SHA256 con secret de
+// "<timestamp>.<body>", en hexadecimal. Incluir el momento del envío permite
+// al receptor rechazar notificaciones repetidas tiempo después.
+func Sign(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature comprueba la cabecera SignatureHeader de una notificación
+// y que no tenga más de tolerance de antigüedad. Es la comprobación que debe
+// hacer el receptor de los callbacks.
+func VerifySignature(secret []byte, header string, body []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return errors.New("malformed signature header")
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return errors.New("signature timestamp out of tolerance")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ErrBlockedDestination se devuelve al entregar un callback a una dirección
+// que no es pública y no está en WebhookNotifier.AllowedNetworks.
+var ErrBlockedDestination = errors.New("callback destination not allowed")
+
+// WebhookNotifier entrega el resultado de los trabajos que terminan a su
+// callback_url mediante un POST firmado, reintentando con backoff los
+// fallos. Cada intento se anota en el registro del trabajo.
+//
+// Como la URL la elige el cliente, el notificador solo se conecta a
+// direcciones públicas: las de loopback, redes privadas, link-local y
+// similares se rechazan al conectar, ya resuelto el nombre, salvo que estén
+// en AllowedNetworks. Las redirecciones no se siguen.
+type WebhookNotifier struct {
+	Client          *http.Client
+	Retry           RetryPolicy    // Intentos y esperas entre ellos; AttemptTimeout acota cada petición
+	AllowedNetworks []netip.Prefix // Redes no públicas a las que se permite entregar callbacks
+	Delivered       Counter        // Notificaciones entregadas
+	Failed          Counter        // Notificaciones abandonadas tras agotar los intentos
+	Logger          *slog.Logger
+
+	store  *JobStore
+	secret []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier crea un notificador que firma con secret y anota las
+// entregas en store.
+//
+// Parámetros:
+//   - store: Registro de los trabajos cuyos callbacks se entregan
+//   - secret: Clave compartida con los receptores para firmar las notificaciones
+//   - retry: Política de reintentos de cada notificación
+//
+// Retorna:
+//   - *WebhookNotifier: Nuevo notificador
+func NewWebhookNotifier(store *JobStore, secret []byte, retry RetryPolicy) *WebhookNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{
+		Logger: slog.Default(),
+		Retry:  retry,
+		store:  store,
+		secret: secret,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: n.checkDestination}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // Con un proxy se comprobaría su dirección, no la del receptor.
+	transport.DialContext = dialer.DialContext
+	n.Client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse // Una redirección podría llevar a una dirección interna.
+		},
+	}
+	return n
+}
+
+// nonPublicNetworks son las redes, además de las que reconoce netip.Addr,
+// a las que no se entregan callbacks salvo que estén en AllowedNetworks.
+var nonPublicNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "Esta" red
+	netip.MustParsePrefix("100.64.0.0/10"), // Espacio compartido (CGNAT)
+}
+
+// checkDestination es el Control del dialer de Client: rechaza con
+// ErrBlockedDestination las conexiones a direcciones que no son públicas,
+// salvo las de AllowedNetworks.
+func (n *WebhookNotifier) checkDestination(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := addrPort.Addr().Unmap()
+	contains := func(p netip.Prefix) bool { return p.Contains(addr) }
+	if slices.ContainsFunc(n.AllowedNetworks, contains) {
+		return nil
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() ||
+		slices.ContainsFunc(nonPublicNetworks, contains) {
+		return fmt.Errorf("%w: %s", ErrBlockedDestination, addr)
+	}
+	return nil
+}
+
+// ParseNetworks lee una lista de redes en notación CIDR separadas por comas,
+// como la de -webhook-allow-networks. Ej: "10.0.0.0/8,fd00::/8".
+func ParseNetworks(s string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	if s == "" {
+		return networks, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("network %q: want CIDR notation like 10.0.0.0/8", entry)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+// Observe inicia en segundo plano la entrega del callback de los trabajos
+// que alcanzan un estado final. Está pensada para registrarse con
+// JobStore.Observe.
+func (n *WebhookNotifier) Observe(rec JobRecord) {
+	if !rec.Status.Terminal() || rec.Callback == nil {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.deliver(rec)
+	}()
+}
+
+// Close espera a que terminen las entregas en curso, incluidos sus
+// reintentos, hasta que ctx expire; entonces abandona las restantes.
+func (n *WebhookNotifier) Close(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
+
+// webhookPayload es el cuerpo de una notificación.
+type webhookPayload struct {
+	Event string    `json:"event"`
+	Job   JobRecord `json:"job"`
+}
+
+// deliver envía la notificación del trabajo hasta que el receptor responde
+// 2xx, hasta agotar los intentos o hasta que se cierra el notificador.
+func (n *WebhookNotifier) deliver(rec JobRecord) {
+	url := rec.Callback.URL
+	event := "job." + string(rec.Status)
+	rec.Callback = nil // El receptor solo necesita el resultado.
+	body, err := json.Marshal(webhookPayload{Event: event, Job: rec})
+	if err != nil {
+		n.Logger.Error("could not encode callback", append(recordAttrs(rec), logError, err)...)
+		return
+	}
+	logger := n.Logger.With(recordAttrs(rec)...).With("url", url)
+	deliveryID := NewJobID()
+
+	for attempt := 1; ; attempt++ {
+		result, retry := n.post(url, event, deliveryID, body)
+		switch {
+		case result.Error == "":
+			n.store.AddDeliveryAttempt(rec.ID, result, CallbackDelivered)
+			n.Delivered.Inc()
+			logger.Info("callback delivered", logAttempt, attempt, logDuration, result.Duration)
+			return
+		case !retry || attempt >= n.Retry.MaxAttempts:
+			n.store.AddDeliveryAttempt(rec.ID, result, CallbackFailed)
+			n.Failed.Inc()
+			logger.Error("callback failed", logAttempt, attempt, logError, result.Error)
+			return
+		}
+		n.store.AddDeliveryAttempt(rec.ID, result, CallbackPending)
+
+		delay := n.Retry.Delay(attempt)
+		logger.Warn("retrying callback", logAttempt, attempt+1, "delay", delay, logError, result.Error)
+		select {
+		case <-time.After(delay):
+		case <-n.ctx.Done():
+			logger.Warn("callback abandoned")
+			return
+		}
+	}
+}
+
+// post realiza un intento de entrega y devuelve su resultado, con Error
+// vacío si el receptor respondió 2xx. El segundo valor indica si el fallo
+// es transitorio: errores de red salvo ErrBlockedDestination, timeouts, 408,
+// 429 y 5xx.
+func (n *WebhookNotifier) post(url, event, deliveryID string, body []byte) (DeliveryAttempt, bool) {
+	ctx := n.ctx
+	if n.Retry.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.Retry.AttemptTimeout)
+		defer cancel()
+	}
+	result := DeliveryAttempt{At: time.Now()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+	timestamp := result.At.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(n.secret, timestamp, body)))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		result.Duration = time.Since(result.At)
+		return result, !errors.Is(err, ErrBlockedDestination)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Permite reutilizar la conexión.
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.Duration = time.Since(result.At)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return result, false
+	}
+	result.Error = "receiver responded " + resp.Status
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return result, retry
+}
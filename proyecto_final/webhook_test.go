@@ -0,0 +1,175 @@
+// Este archivo contiene pruebas unitarias para la entrega de callbacks. El
+// receptor es un servidor httptest local que responde según cada caso y
+// comprueba la firma de cada notificación.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWebhookNotifierDelivers verifica la firma, los reintentos y el
+// registro de los intentos de entrega.
+func TestWebhookNotifierDelivers(t *testing.T) {
+	secret := []byte("s3cr3t")
+	testCases := []struct {
+		name         string
+		responses    []int // Respuesta del receptor a cada intento
+		maxAttempts  int
+		wantState    CallbackState
+		wantAttempts int
+	}{
+		{name: "first attempt", responses: []int{200}, maxAttempts: 3, wantState: CallbackDelivered, wantAttempts: 1},
+		{name: "retries server errors", responses: []int{503, 500, 204}, maxAttempts: 3, wantState: CallbackDelivered, wantAttempts: 3},
+		{name: "gives up", responses: []int{503, 429, 200}, maxAttempts: 2, wantState: CallbackFailed, wantAttempts: 2},
+		{name: "client error", responses: []int{400, 200}, maxAttempts: 3, wantState: CallbackFailed, wantAttempts: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				calls    int
+				payloads []webhookPayload
+			)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if err := VerifySignature(secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+					t.Errorf("VerifySignature() error = %v", err)
+				}
+				var p webhookPayload
+				if err := json.Unmarshal(body, &p); err != nil {
+					t.Errorf("decode payload: %v", err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				payloads = append(payloads, p)
+				w.WriteHeader(tc.responses[calls])
+				calls++
+			}))
+			defer receiver.Close()
+
+			store := NewJobStore()
+			notifier := NewWebhookNotifier(store, secret, RetryPolicy{
+				MaxAttempts: tc.maxAttempts,
+				Backoff:     time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			})
+			notifier.AllowedNetworks = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")} // El receptor es local.
+			store.Observe(notifier.Observe)
+
+			job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+			job.CallbackURL = receiver.URL
+			store.Add(job)
+			store.MarkRunning(job.ID, 0)
+			store.MarkDone(job.ID, "55")
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			notifier.Close(ctx)
+
+			rec, _ := store.Get(job.ID)
+			if rec.Callback.State != tc.wantState || len(rec.Callback.Attempts) != tc.wantAttempts {
+				t.Errorf("callback = %s after %d attempt(s); want %s after %d", rec.Callback.State, len(rec.Callback.Attempts), tc.wantState, tc.wantAttempts)
+			}
+			if last := rec.Callback.Attempts[len(rec.Callback.Attempts)-1]; last.StatusCode != tc.responses[tc.wantAttempts-1] {
+				t.Errorf("last attempt status = %d; want %d", last.StatusCode, tc.responses[tc.wantAttempts-1])
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, p := range payloads {
+				if p.Event != "job.done" || p.Job.ID != job.ID || p.Job.Result == nil || *p.Job.Result != "55" {
+					t.Errorf("payload = %+v; want job.done for %s with result 55", p, job.ID)
+				}
+			}
+		})
+	}
+}
+
+// TestWebhookNotifierBlocksDestinations verifica que no se entreguen
+// callbacks a direcciones locales no permitidas ni se sigan redirecciones,
+// y que esos fallos no se reintenten.
+func TestWebhookNotifierBlocksDestinations(t *testing.T) {
+	var internalCalls atomic.Int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalCalls.Add(1)
+	}))
+	defer internal.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer redirector.Close()
+
+	testCases := []struct {
+		name       string
+		url        string
+		allowed    []netip.Prefix
+		wantStatus int
+	}{
+		{name: "loopback", url: internal.URL},
+		{name: "other allowed network", url: internal.URL, allowed: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		{name: "redirect", url: redirector.URL, allowed: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}, wantStatus: http.StatusFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewJobStore()
+			notifier := NewWebhookNotifier(store, []byte("s3cr3t"), RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			notifier.AllowedNetworks = tc.allowed
+			store.Observe(notifier.Observe)
+
+			job := NewJob("test", 10, 0, time.Time{}, PriorityNormal)
+			job.CallbackURL = tc.url
+			store.Add(job)
+			store.MarkDone(job.ID, "55")
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			notifier.Close(ctx)
+
+			rec, _ := store.Get(job.ID)
+			if rec.Callback.State != CallbackFailed || len(rec.Callback.Attempts) != 1 {
+				t.Fatalf("callback = %s after %d attempt(s); want failed after 1", rec.Callback.State, len(rec.Callback.Attempts))
+			}
+			if got := rec.Callback.Attempts[0].StatusCode; got != tc.wantStatus {
+				t.Errorf("attempt status = %d; want %d (%s)", got, tc.wantStatus, rec.Callback.Attempts[0].Error)
+			}
+			if got := internalCalls.Load(); got != 0 {
+				t.Errorf("internal server received %d requests; want 0", got)
+			}
+		})
+	}
+}
+
+// TestVerifySignature verifica que se rechacen las firmas alteradas o antiguas.
+func TestVerifySignature(t *testing.T) {
+	secret, body := []byte("s3cr3t"), []byte(`{"event":"job.done"}`)
+	now := time.Now().Unix()
+	valid := "t=" + strconv.FormatInt(now, 10) + ",v1=" + Sign(secret, now, body)
+	old := "t=" + strconv.FormatInt(now-600, 10) + ",v1=" + Sign(secret, now-600, body)
+
+	testCases := []struct {
+		name    string
+		secret  []byte
+		header  string
+		wantErr bool
+	}{
+		{name: "valid", secret: secret, header: valid},
+		{name: "wrong secret", secret: []byte("other"), header: valid, wantErr: true},
+		{name: "too old", secret: secret, header: old, wantErr: true},
+		{name: "malformed", secret: secret, header: "v1=abc", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifySignature(tc.secret, tc.header, body, 5*time.Minute)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("VerifySignature() error = %v; want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}